@@ -0,0 +1,85 @@
+package extyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Warning is a non-fatal issue found during unmarshalling, e.g. use of a deprecated key
+type Warning struct {
+	Line, Column int
+	//Key is the YAML key in the document
+	Key     string
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("line %d, column %d: %v: %v", w.Line, w.Column, w.Key, w.Message)
+}
+
+// OnWarning sets a callback that gets called for each Warning during unmarshalling
+func OnWarning(fn func(w Warning)) Option {
+	return func(o *options) {
+		o.onWarning = fn
+	}
+}
+
+// quiet returns a copy of o that doesn't report warnings
+func (o *options) quiet() *options {
+	r := *o
+	r.onWarning = nil
+	return &r
+}
+
+func (o *options) warn(k *yaml.Node, msg string) {
+	if o.onWarning != nil {
+		o.onWarning(Warning{Line: k.Line, Column: k.Column, Key: k.Value, Message: msg})
+	}
+}
+
+// aliases returns the alias keys of the field specified in ExtTag, e.g. `extyaml:"alias=old_name|older_name"`
+func aliases(field reflect.StructField) []string {
+	v := parseExtTag(field.Tag)["alias"]
+	if v == "" {
+		return nil
+	}
+	return strings.Split(v, "|")
+}
+
+// applyAliases changes alias keys under n into the YAML key of the field, t is the Go type n maps to;
+// a Warning is reported for each key of a field with `extyaml:"deprecated=<msg>"`,
+// if the field has alias, then only use of alias is deprecated, otherwise the field itself is deprecated.
+func applyAliases(n *yaml.Node, t reflect.Type, o *options) error {
+	return walkNode(n, t, func(n *yaml.Node, t reflect.Type) error {
+		if n.Kind != yaml.MappingNode || t == nil || t.Kind() != reflect.Struct {
+			return nil
+		}
+		for _, f := range yamlFields(t) {
+			opts := parseExtTag(f.field.Tag)
+			alist := aliases(f.field)
+			for _, alias := range alist {
+				i := keyIndex(n, alias)
+				if i < 0 {
+					continue
+				}
+				k := n.Content[i]
+				if j := keyIndex(n, f.key); j >= 0 {
+					return newPosError(k, fmt.Errorf("both %v and its alias %v are specified", f.key, alias))
+				}
+				if msg, deprecated := opts["deprecated"]; deprecated {
+					o.warn(k, fmt.Sprintf("deprecated key, %v", msg))
+				}
+				k.Value = f.key
+			}
+			if msg, deprecated := opts["deprecated"]; deprecated && len(alist) == 0 {
+				if i := keyIndex(n, f.key); i >= 0 {
+					o.warn(n.Content[i], fmt.Sprintf("deprecated key, %v", msg))
+				}
+			}
+		}
+		return nil
+	})
+}
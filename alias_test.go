@@ -0,0 +1,106 @@
+package extyaml_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type aliasConfig struct {
+	ServerName string    `yaml:"server_name" extyaml:"alias=servername|name,deprecated=use server_name"`
+	Subnet     net.IPNet `extyaml:"alias=prefix"`
+	Legacy     int       `extyaml:"deprecated=will be removed"`
+}
+
+func TestAlias(t *testing.T) {
+	var warnings []extyaml.Warning
+	cfg := new(aliasConfig)
+	err := extyaml.UnmarshalExt([]byte("servername: s1\nprefix: 10.0.0.0/8\nlegacy: 1\n"), cfg,
+		extyaml.OnWarning(func(w extyaml.Warning) {
+			warnings = append(warnings, w)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServerName != "s1" || cfg.Subnet.String() != "10.0.0.0/8" || cfg.Legacy != 1 {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expect 2 warnings, got %v", warnings)
+	}
+	if warnings[0].Key != "servername" || warnings[0].Line != 1 || !strings.Contains(warnings[0].Message, "use server_name") {
+		t.Fatalf("unexpected warning %v", warnings[0])
+	}
+	if warnings[1].Key != "legacy" || warnings[1].Line != 3 {
+		t.Fatalf("unexpected warning %v", warnings[1])
+	}
+	//new name is not deprecated
+	warnings = nil
+	err = extyaml.UnmarshalExt([]byte("server_name: s2\n"), cfg, extyaml.OnWarning(func(w extyaml.Warning) {
+		warnings = append(warnings, w)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServerName != "s2" || len(warnings) != 0 {
+		t.Fatalf("unexpected result %+v, warnings %v", cfg, warnings)
+	}
+	//marshal always use new name
+	buf, err := extyaml.MarshalExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(buf), "server_name: s2\nsubnet: 10.0.0.0/8\n") {
+		t.Fatalf("unexpected marshal result %v", string(buf))
+	}
+	//both old and new name
+	err = extyaml.UnmarshalExt([]byte("server_name: s1\nname: s2\n"), cfg)
+	var perr *extyaml.PosError
+	if !errors.As(err, &perr) || perr.Line != 2 {
+		t.Fatalf("expect error at line 2, got %v", err)
+	}
+}
+
+type aliasCommaConfig struct {
+	Old int `extyaml:"deprecated='use new, or newer',alias=older"`
+	New int
+}
+
+func TestAliasMergeWarning(t *testing.T) {
+	var warnings []extyaml.Warning
+	onWarning := extyaml.OnWarning(func(w extyaml.Warning) {
+		warnings = append(warnings, w)
+	})
+	cfg := new(aliasConfig)
+	err := extyaml.MergeExtWithOptions(cfg, [][]byte{[]byte("servername: s1\n"), []byte("subnet: 10.0.0.0/8\nlegacy: 2\n")}, onWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ServerName != "s1" || cfg.Legacy != 2 {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	if len(warnings) != 2 || warnings[0].Key != "servername" || warnings[1].Key != "legacy" || warnings[1].Line != 2 {
+		t.Fatalf("unexpected warnings %v", warnings)
+	}
+	//the default is not warned
+	warnings = nil
+	err = extyaml.UnmarshalExtDefault([]byte("server_name: s2\n"), cfg, aliasConfig{Legacy: 1}, onWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("unexpected warnings %v", warnings)
+	}
+
+	warnings = nil
+	err = extyaml.UnmarshalExt([]byte("older: 1\n"), new(aliasCommaConfig), onWarning)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(warnings) != 1 || warnings[0].Message != "deprecated key, use new, or newer" {
+		t.Fatalf("unexpected warnings %v", warnings)
+	}
+}
@@ -0,0 +1,118 @@
+package extyaml
+
+import (
+	"crypto/sha256"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Canonical is an Option that marshals in canonical form: keys of Go maps are sorted, by the ToStr form for registered
+// key types; unknown keys kept by the remain field are sorted too; scalars are in plain style unless quoting is required, and there is no comment.
+// the output of same value is always same, except encrypted fields.
+func Canonical() Option {
+	return func(o *options) {
+		o.canonical = true
+	}
+}
+
+// canonicalize converts n to canonical form, t is the Go type n maps to
+func canonicalize(n *yaml.Node, t reflect.Type) {
+	normalize(n)
+	walkNode(n, t, func(n *yaml.Node, t reflect.Type) error {
+		if n.Kind == yaml.MappingNode && t != nil && t.Kind() == reflect.Map {
+			sortMapping(n)
+		}
+		if n.Kind == yaml.MappingNode && t != nil && t.Kind() == reflect.Struct && remainField(t) != nil {
+			sortUnknown(n, t)
+		}
+		return nil
+	})
+}
+
+// sortUnknown sorts the unknown keys spliced from the remain field in mapping n of struct type t,
+// they are kept at their positions among the known keys; mappings under an unknown key are sorted too
+func sortUnknown(n *yaml.Node, t reflect.Type) {
+	var pos []int
+	unknown := &yaml.Node{Kind: yaml.MappingNode}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if _, ok := yamlFieldByKey(t, n.Content[i].Value); !ok {
+			pos = append(pos, i)
+			unknown.Content = append(unknown.Content, n.Content[i], n.Content[i+1])
+			sortAllMappings(n.Content[i+1])
+		}
+	}
+	sortMapping(unknown)
+	for j, i := range pos {
+		n.Content[i], n.Content[i+1] = unknown.Content[2*j], unknown.Content[2*j+1]
+	}
+}
+
+// sortAllMappings sorts every mapping in n and its descendants
+func sortAllMappings(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		sortMapping(n)
+	}
+	for _, c := range n.Content {
+		sortAllMappings(c)
+	}
+}
+
+// normalize removes comments and styles of n and all its descendants
+func normalize(n *yaml.Node) {
+	n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+	n.Style = 0
+	for _, c := range n.Content {
+		normalize(c)
+	}
+}
+
+// sortMapping sorts the pairs of mapping n by key, numeric keys are sorted by value and before other keys
+func sortMapping(n *yaml.Node) {
+	type pair struct {
+		k, v *yaml.Node
+	}
+	pairs := make([]pair, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		pairs = append(pairs, pair{n.Content[i], n.Content[i+1]})
+	}
+	number := func(k *yaml.Node) (float64, bool) {
+		switch k.ShortTag() {
+		case "!!int", "!!float":
+			f, err := strconv.ParseFloat(k.Value, 64)
+			return f, err == nil
+		}
+		return 0, false
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		a, aok := number(pairs[i].k)
+		b, bok := number(pairs[j].k)
+		switch {
+		case aok && bok && a != b:
+			return a < b
+		case aok != bok:
+			return aok
+		}
+		return pairs[i].k.Value < pairs[j].k.Value
+	})
+	for i, p := range pairs {
+		n.Content[2*i], n.Content[2*i+1] = p.k, p.v
+	}
+}
+
+// Fingerprint returns the SHA-256 hash of in marshaled in canonical form,
+// values of secret and encrypted fields are included as they are, without redaction or encryption.
+func Fingerprint(in any) ([32]byte, error) {
+	n, err := marshalNode(in, newOptions(nil))
+	if err != nil {
+		return [32]byte{}, err
+	}
+	canonicalize(n, reflect.TypeOf(in))
+	buf, err := yaml.Marshal(n)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	return sha256.Sum256(buf), nil
+}
@@ -0,0 +1,111 @@
+package extyaml_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hujun-open/extyaml"
+	"gopkg.in/yaml.v3"
+)
+
+type canonicalConfig struct {
+	Name   string
+	Times  map[time.Time]int
+	Ports  map[int]string
+	Macs   map[string]net.HardwareAddr
+	Extra  any
+	Remain map[string]yaml.Node `extyaml:",remain"`
+}
+
+func TestCanonical(t *testing.T) {
+	extyaml.RegisterExt[time.Time](timeToStr, timeFromStr)
+	t1, _ := time.Parse(time.RFC3339, "2023-01-02T00:00:00Z")
+	t2, _ := time.Parse(time.RFC3339, "2024-05-03T00:00:00Z")
+	newConfig := func() *canonicalConfig {
+		cfg := new(canonicalConfig)
+		err := extyaml.UnmarshalExt([]byte(`
+name: "r1" # comment
+unknown: {b: 1, a: 'x'}
+`), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Times = map[time.Time]int{t1: 1, t2: 2}
+		cfg.Ports = map[int]string{10: "a", 9: "b", 100: "c"}
+		cfg.Macs = map[string]net.HardwareAddr{"eth1": {1, 2, 3, 4, 5, 6}, "eth0": {6, 5, 4, 3, 2, 1}}
+		cfg.Extra = map[string]any{"z": 1, "y": []any{"b", "a"}}
+		return cfg
+	}
+	buf, err := extyaml.MarshalExt(newConfig(), extyaml.Canonical())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `name: r1
+times:
+    Fri, 03 May 2024 00:00:00 UTC: 2
+    Mon, 02 Jan 2023 00:00:00 UTC: 1
+ports:
+    9: b
+    10: a
+    100: c
+macs:
+    eth0: 06:05:04:03:02:01
+    eth1: 01:02:03:04:05:06
+extra:
+    y:
+        - b
+        - a
+    z: 1
+unknown:
+    a: x
+    b: 1
+`
+	if string(buf) != expect {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+	fp, err := extyaml.Fingerprint(newConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		again, err := extyaml.Fingerprint(newConfig())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if again != fp {
+			t.Fatal("fingerprint of same value changed")
+		}
+	}
+	cfg := newConfig()
+	cfg.Ports[9] = "x"
+	changed, err := extyaml.Fingerprint(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed == fp {
+		t.Fatal("fingerprint of different value is same")
+	}
+}
+
+func TestFingerprintUnknownOrder(t *testing.T) {
+	var fps [][32]byte
+	for _, doc := range []string{
+		"name: r1\nz: 1\na: {y: 2, x: 1}\n",
+		"a: {x: 1, y: 2}\nname: r1\nz: 1\n",
+	} {
+		cfg := new(canonicalConfig)
+		err := extyaml.UnmarshalExt([]byte(doc), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fp, err := extyaml.Fingerprint(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fps = append(fps, fp)
+	}
+	if fps[0] != fps[1] {
+		t.Fatal("fingerprint changed with the order of unknown keys")
+	}
+}
@@ -0,0 +1,79 @@
+package extyaml
+
+import (
+	"reflect"
+)
+
+// deepCopy returns a deep copy of v, pointers, maps, slices and interface{} values are not shared with v;
+// non-export fields are copied shallowly since they are not accessible
+func deepCopy(v reflect.Value) reflect.Value {
+	r := reflect.New(v.Type()).Elem()
+	copyValue(r, v, make(map[uintptr]reflect.Value))
+	return r
+}
+
+// copyValue deep copies src into dst, copied records the copy of each visited pointer, so shared and cyclic pointers are kept
+func copyValue(dst, src reflect.Value, copied map[uintptr]reflect.Value) {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		if c, ok := copied[src.Pointer()]; ok && c.Type() == src.Type() {
+			dst.Set(c)
+			return
+		}
+		c := reflect.New(src.Type().Elem())
+		copied[src.Pointer()] = c
+		copyValue(c.Elem(), src.Elem(), copied)
+		dst.Set(c)
+	case reflect.Map:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		c := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			k := reflect.New(src.Type().Key()).Elem()
+			copyValue(k, iter.Key(), copied)
+			e := reflect.New(src.Type().Elem()).Elem()
+			copyValue(e, iter.Value(), copied)
+			c.SetMapIndex(k, e)
+		}
+		dst.Set(c)
+	case reflect.Slice:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		c := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			copyValue(c.Index(i), src.Index(i), copied)
+		}
+		dst.Set(c)
+	case reflect.Array:
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i), copied)
+		}
+	case reflect.Interface:
+		if src.IsNil() {
+			dst.Set(src)
+			return
+		}
+		e := reflect.New(src.Elem().Type()).Elem()
+		copyValue(e, src.Elem(), copied)
+		dst.Set(e)
+	case reflect.Struct:
+		//copy all fields including non-export ones, then deep copy export fields
+		dst.Set(src)
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				copyValue(dst.Field(i), src.Field(i), copied)
+			}
+		}
+	default:
+		dst.Set(src)
+	}
+}
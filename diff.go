@@ -0,0 +1,324 @@
+package extyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ChangeType is the type of a Change
+type ChangeType int
+
+const (
+	ChangeModified ChangeType = iota
+	ChangeAdded
+	ChangeRemoved
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	}
+	return "modified"
+}
+
+// Change is a changed value between two values of the same type
+type Change struct {
+	//Path is the path of the changed value, e.g. "servers[0].subnet"
+	Path string
+	Type ChangeType
+	//Old and New are the values rendered via registered codecs, in YAML flow style if it is not a scalar;
+	//Old is empty if the value is added, New is empty if the value is removed
+	Old, New string
+}
+
+// String returns the change in format of "<path>: <old> -> <new>", "<path>: + <new>" or "<path>: - <old>"
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("%v: + %v", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("%v: - %v", c.Path, c.Old)
+	}
+	return fmt.Sprintf("%v: %v -> %v", c.Path, c.Old, c.New)
+}
+
+// DiffExt returns the changed values from a to b, a and b must be same type;
+// values are compared and rendered via registered codecs, map entries are compared by key, sequence elements by index.
+// values of secret fields are compared by their real values, but rendered as SecretMask unless Unredacted option is specified.
+func DiffExt(a, b any, opts ...Option) ([]Change, error) {
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return nil, fmt.Errorf("a and b are not same type")
+	}
+	o := newOptions(opts)
+	an, err := marshalNode(a, o)
+	if err != nil {
+		return nil, err
+	}
+	bn, err := marshalNode(b, o)
+	if err != nil {
+		return nil, err
+	}
+	d := &differ{}
+	if !o.unredacted && a != nil {
+		d.secrets = map[*yaml.Node]bool{}
+		for _, n := range []*yaml.Node{an, bn} {
+			fieldValues(n, reflect.TypeOf(a), SecretOpt, func(v *yaml.Node) error {
+				markSecret(v, d.secrets)
+				return nil
+			})
+		}
+	}
+	d.diff(an, bn, "")
+	return d.changes, nil
+}
+
+// differ collects the changes between two nodes
+type differ struct {
+	//secrets is the value nodes of secret fields and their descendants, rendered as SecretMask
+	secrets map[*yaml.Node]bool
+	changes []Change
+}
+
+// diff appends the changes from a to b, path is the path of a and b
+func (d *differ) diff(a, b *yaml.Node, path string) {
+	if nodeEqual(a, b) {
+		return
+	}
+	switch {
+	case a.Kind == yaml.MappingNode && b.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(a.Content); i += 2 {
+			k := a.Content[i].Value
+			p := appendKey(path, k)
+			if bv := mappingValue(b, k); bv != nil {
+				d.diff(a.Content[i+1], bv, p)
+			} else {
+				d.changes = append(d.changes, Change{Path: p, Type: ChangeRemoved, Old: d.render(a.Content[i+1])})
+			}
+		}
+		for i := 0; i+1 < len(b.Content); i += 2 {
+			if keyIndex(a, b.Content[i].Value) < 0 {
+				d.changes = append(d.changes, Change{Path: appendKey(path, b.Content[i].Value), Type: ChangeAdded, New: d.render(b.Content[i+1])})
+			}
+		}
+	case a.Kind == yaml.SequenceNode && b.Kind == yaml.SequenceNode:
+		for i, item := range a.Content {
+			p := appendIndex(path, i)
+			if i < len(b.Content) {
+				d.diff(item, b.Content[i], p)
+			} else {
+				d.changes = append(d.changes, Change{Path: p, Type: ChangeRemoved, Old: d.render(item)})
+			}
+		}
+		for i := len(a.Content); i < len(b.Content); i++ {
+			d.changes = append(d.changes, Change{Path: appendIndex(path, i), Type: ChangeAdded, New: d.render(b.Content[i])})
+		}
+	default:
+		d.changes = append(d.changes, Change{Path: path, Type: ChangeModified, Old: d.render(a), New: d.render(b)})
+	}
+}
+
+// render renders n via renderNode, secret values under n are masked
+func (d *differ) render(n *yaml.Node) string {
+	if len(d.secrets) == 0 {
+		return renderNode(n)
+	}
+	return renderNode(maskedCopy(n, d.secrets))
+}
+
+// markSecret adds n and its descendants except mapping keys into secrets
+func markSecret(n *yaml.Node, secrets map[*yaml.Node]bool) {
+	secrets[n] = true
+	for i, c := range n.Content {
+		if n.Kind == yaml.MappingNode && i%2 == 0 {
+			continue
+		}
+		markSecret(c, secrets)
+	}
+}
+
+// maskedCopy returns a deep copy of n, nodes in secrets are masked via maskNode
+func maskedCopy(n *yaml.Node, secrets map[*yaml.Node]bool) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = maskedCopy(child, secrets)
+	}
+	if secrets[n] {
+		maskNode(&c)
+	}
+	return &c
+}
+
+// renderNode returns n as a single line of YAML, a scalar is rendered as its value
+func renderNode(n *yaml.Node) string {
+	if n.Kind == yaml.ScalarNode {
+		if n.ShortTag() == "!!null" {
+			return "null"
+		}
+		return n.Value
+	}
+	c := flowCopy(n)
+	buf, err := yaml.Marshal(c)
+	if err != nil {
+		return fmt.Sprint(err)
+	}
+	return strings.TrimSpace(string(buf))
+}
+
+// flowCopy returns a copy of n in flow style
+func flowCopy(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Style |= yaml.FlowStyle
+	c.HeadComment, c.LineComment, c.FootComment = "", "", ""
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = flowCopy(child)
+	}
+	return &c
+}
+
+// UnifiedDiffExt returns the unified diff from YAML of a to YAML of b, with 3 lines of context;
+// an empty string is returned if there is no difference.
+// values of secret fields are SecretMask unless Unredacted option is specified, so a changed secret value is not shown.
+func UnifiedDiffExt(a, b any, opts ...Option) (string, error) {
+	o := newOptions(opts)
+	abuf, err := marshalRedacted(a, o)
+	if err != nil {
+		return "", err
+	}
+	bbuf, err := marshalRedacted(b, o)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff("a", "b", splitLines(string(abuf)), splitLines(string(bbuf)), 3), nil
+}
+
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// diffOp is a line in diff, op is ' ', '-' or '+'
+type diffOp struct {
+	op   byte
+	line string
+	//ai and bi are the line index in a and b before this line
+	ai, bi int
+}
+
+// lineDiff returns the line diff from a to b, based on longest common subsequence
+func lineDiff(a, b []string) []diffOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = lcs[i+1][j]
+				if lcs[i][j+1] > lcs[i][j] {
+					lcs[i][j] = lcs[i][j+1]
+				}
+			}
+		}
+	}
+	var ops []diffOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, diffOp{op: ' ', line: a[i], ai: i, bi: j})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			ops = append(ops, diffOp{op: '+', line: b[j], ai: i, bi: j})
+			j++
+		default:
+			ops = append(ops, diffOp{op: '-', line: a[i], ai: i, bi: j})
+			i++
+		}
+	}
+	return ops
+}
+
+// unifiedDiff renders the diff from a to b in unified format with n lines of context
+func unifiedDiff(aname, bname string, a, b []string, n int) string {
+	ops := lineDiff(a, b)
+	var sb strings.Builder
+	for start := 0; start < len(ops); {
+		//find next change
+		for start < len(ops) && ops[start].op == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintf(&sb, "--- %v\n+++ %v\n", aname, bname)
+		}
+		first := start - n
+		if first < 0 {
+			first = 0
+		}
+		//extend the hunk until there are more than 2n unchanged lines
+		end, same := start, 0
+		for end < len(ops) && (ops[end].op != ' ' || same < 2*n) {
+			if ops[end].op == ' ' {
+				same++
+			} else {
+				same = 0
+			}
+			end++
+		}
+		//keep n lines of context after the last change
+		last := end
+		for last > start && ops[last-1].op == ' ' {
+			last--
+		}
+		last += n
+		if last > len(ops) {
+			last = len(ops)
+		}
+		acount, bcount := 0, 0
+		for _, op := range ops[first:last] {
+			if op.op != '+' {
+				acount++
+			}
+			if op.op != '-' {
+				bcount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%v +%v @@\n", hunkRange(ops[first].ai, acount), hunkRange(ops[first].bi, bcount))
+		for _, op := range ops[first:last] {
+			sb.WriteByte(op.op)
+			sb.WriteString(op.line)
+			if !strings.HasSuffix(op.line, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = last
+	}
+	return sb.String()
+}
+
+// hunkRange returns the range of a hunk in unified format, start is 0 based
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
@@ -0,0 +1,134 @@
+package extyaml_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type diffServer struct {
+	Name   string
+	Subnet net.IPNet
+}
+
+type diffConfig struct {
+	Name    string
+	Servers []diffServer
+	Labels  map[string]string
+	Sub     *diffServer
+}
+
+func TestDiffExt(t *testing.T) {
+	a := diffConfig{
+		Name: "n",
+		Servers: []diffServer{
+			{Name: "s1", Subnet: mustCIDR("10.0.0.0/24")},
+			{Name: "s2", Subnet: mustCIDR("10.0.2.0/24")},
+		},
+		Labels: map[string]string{"a": "1", "b": "2"},
+	}
+	b := a
+	b.Servers = []diffServer{
+		{Name: "s1", Subnet: mustCIDR("10.0.1.0/24")},
+		{Name: "s2", Subnet: mustCIDR("10.0.2.0/24")},
+		{Name: "s3", Subnet: mustCIDR("10.0.3.0/24")},
+	}
+	b.Labels = map[string]string{"a": "1", "c": "3"}
+	b.Sub = &diffServer{Name: "x"}
+	changes, err := extyaml.DiffExt(a, &b)
+	if err == nil {
+		t.Fatal("expect error for different types")
+	}
+	changes, err = extyaml.DiffExt(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"servers[0].subnet: 10.0.0.0/24 -> 10.0.1.0/24",
+		"servers[2]: + {name: s3, subnet: 10.0.3.0/24}",
+		"labels.b: - 2",
+		"labels.c: + 3",
+		`sub: null -> {name: x, subnet: ""}`,
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("unexpected changes %v", changes)
+	}
+	for i, c := range changes {
+		if c.String() != expected[i] {
+			t.Fatalf("change %d is %v, expect %v", i, c, expected[i])
+		}
+	}
+	if changes[1].Type != extyaml.ChangeAdded || changes[2].Type != extyaml.ChangeRemoved || changes[0].Old != "10.0.0.0/24" {
+		t.Fatalf("unexpected changes %#v", changes)
+	}
+	changes, err = extyaml.DiffExt(a, a)
+	if err != nil || len(changes) != 0 {
+		t.Fatalf("expect no change, got %v, %v", changes, err)
+	}
+
+	d, err := extyaml.UnifiedDiffExt(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedDiff := `--- a
++++ b
+@@ -1,10 +1,14 @@
+ name: "n"
+ servers:
+     - name: s1
+-      subnet: 10.0.0.0/24
++      subnet: 10.0.1.0/24
+     - name: s2
+       subnet: 10.0.2.0/24
++    - name: s3
++      subnet: 10.0.3.0/24
+ labels:
+     a: "1"
+-    b: "2"
+-sub: null
++    c: "3"
++sub:
++    name: x
++    subnet: ""
+`
+	if d != expectedDiff {
+		t.Fatalf("unified diff %v is different from expected %v", d, expectedDiff)
+	}
+	if d, _ := extyaml.UnifiedDiffExt(a, a); d != "" {
+		t.Fatalf("expect empty diff, got %v", d)
+	}
+}
+
+func TestDiffSecret(t *testing.T) {
+	a := &secretConfig{Auth: secretAuth{User: "u", Password: "p1"}, Keys: map[string]string{"k": "v1"}}
+	b := &secretConfig{Auth: secretAuth{User: "u", Password: "p2"}, Keys: map[string]string{"k": "v2", "x": "y"}}
+	changes, err := extyaml.DiffExt(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var list []string
+	for _, c := range changes {
+		list = append(list, c.String())
+	}
+	if strings.Join(list, "\n") != "auth.password: ****** -> ******\nkeys.k: ****** -> ******\nkeys.x: + ******" {
+		t.Fatalf("unexpected changes %v", list)
+	}
+	changes, err = extyaml.DiffExt(a, b, extyaml.Unredacted())
+	if err != nil || len(changes) != 3 || changes[0].String() != "auth.password: p1 -> p2" {
+		t.Fatalf("unexpected changes %v, %v", changes, err)
+	}
+
+	d, err := extyaml.UnifiedDiffExt(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(d, "p1") || strings.Contains(d, "v1") || !strings.Contains(d, "+    x: '******'") {
+		t.Fatalf("unexpected diff\n%v", d)
+	}
+	d, err = extyaml.UnifiedDiffExt(a, b, extyaml.Unredacted())
+	if err != nil || !strings.Contains(d, "-    password: p1") {
+		t.Fatalf("unexpected diff %v\n%v", err, d)
+	}
+}
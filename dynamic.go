@@ -0,0 +1,187 @@
+package extyaml
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dynamicExt is the converted type of an interface{} value, the registered type stored in it is only known at runtime;
+// the value is converted when marshaling, the node is converted according to the tags when translating back
+type dynamicExt struct {
+	//value is the orig value, set by translateStructInline
+	value any
+	//o is the options of marshaling
+	o *options
+	//node is set by UnmarshalYAML
+	node *yaml.Node
+}
+
+var (
+	dynamicExtPtrType = reflect.TypeOf(&dynamicExt{})
+	emptyInterfaceInt = reflect.TypeOf((*any)(nil)).Elem()
+)
+
+func (ext dynamicExt) MarshalYAML() (interface{}, error) {
+	v := reflect.ValueOf(ext.value)
+	if !v.IsValid() || (v.Kind() == reflect.Pointer && v.IsNil()) {
+		return nil, nil
+	}
+	o := ext.o
+	if o == nil {
+		o = newOptions(nil)
+	}
+	return marshalNode(ext.value, o)
+}
+
+func (ext *dynamicExt) UnmarshalYAML(value *yaml.Node) error {
+	ext.node = value
+	return nil
+}
+
+// DecodeDynamicTags makes tagged scalars in dynamic positions (interface{} values, including values of map[string]any)
+// decode into the registered type of the tag, e.g. "!cidr 10.0.0.0/8" decodes into a net.IPNet;
+// the tag of a registered type is specified by WithTag() in RegisterExt.
+func DecodeDynamicTags() Option {
+	return func(o *options) {
+		o.dynamicTags = true
+	}
+}
+
+// translateDynamic translates between in and out when one of them is a dynamicExt, return false if neither is
+func translateDynamic(in any, rV reflect.Value, toExt bool, o *options) bool {
+	if toExt {
+		if rV.Type().Elem() != dynamicExtPtrType {
+			return false
+		}
+		if v := reflect.ValueOf(in); v.IsValid() && v.Kind() == reflect.Pointer && v.Type().Elem() == emptyInterfaceInt {
+			//pointer to interface{}
+			in = nil
+			if !v.IsNil() {
+				in = v.Elem().Interface()
+			}
+		}
+		if in == nil {
+			rV.Elem().Set(reflect.Zero(dynamicExtPtrType))
+			return true
+		}
+		rV.Elem().Set(reflect.ValueOf(&dynamicExt{value: in, o: o}))
+		return true
+	}
+	ext, ok := in.(*dynamicExt)
+	if !ok {
+		return false
+	}
+	target := rV.Elem()
+	if target.Kind() == reflect.Pointer {
+		//pointer to interface{}
+		if ext == nil {
+			target.Set(reflect.Zero(target.Type()))
+			return true
+		}
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		target = target.Elem()
+	}
+	var val any
+	switch {
+	case ext == nil:
+	case ext.node != nil:
+		var err error
+		val, err = decodeDynamic(ext.node, o)
+		if err != nil {
+			panic(translateError{err: err})
+		}
+	default:
+		//not in the YAML document, keep orig value
+		val = ext.value
+	}
+	if val == nil {
+		target.Set(reflect.Zero(target.Type()))
+		return true
+	}
+	target.Set(reflect.ValueOf(val))
+	return true
+}
+
+// decodeDynamic decodes n into a interface{} value,
+// tagged scalars are decoded into registered type if o.dynamicTags is true
+func decodeDynamic(n *yaml.Node, o *options) (any, error) {
+	if !o.dynamicTags {
+		return decodeGeneric(n)
+	}
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return nil, nil
+		}
+		return decodeDynamic(n.Content[0], o)
+	case yaml.AliasNode:
+		return decodeDynamic(n.Alias, o)
+	case yaml.ScalarNode:
+		if rt := RegisteredTypes.getByTag(n.Tag); rt != nil {
+			val, err := rt.fromStr(n.Value)
+			if err != nil {
+				return nil, newPosError(n, err)
+			}
+			return val, nil
+		}
+	case yaml.SequenceNode:
+		list := make([]any, len(n.Content))
+		for i, c := range n.Content {
+			val, err := decodeDynamic(c, o)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = val
+		}
+		return list, nil
+	case yaml.MappingNode:
+		strKeys := true
+		for i := 0; i < len(n.Content); i += 2 {
+			if n.Content[i].ShortTag() == "!!merge" {
+				//leave merge key to yaml decoder
+				return decodeGeneric(n)
+			}
+			if n.Content[i].ShortTag() != "!!str" {
+				strKeys = false
+			}
+		}
+		if strKeys {
+			m := make(map[string]any)
+			for i := 0; i < len(n.Content); i += 2 {
+				val, err := decodeDynamic(n.Content[i+1], o)
+				if err != nil {
+					return nil, err
+				}
+				m[n.Content[i].Value] = val
+			}
+			return m, nil
+		}
+		m := make(map[any]any)
+		for i := 0; i < len(n.Content); i += 2 {
+			key, err := decodeDynamic(n.Content[i], o)
+			if err != nil {
+				return nil, err
+			}
+			if key != nil && !reflect.TypeOf(key).Comparable() {
+				return nil, newPosError(n.Content[i], fmt.Errorf("invalid map key %v", key))
+			}
+			val, err := decodeDynamic(n.Content[i+1], o)
+			if err != nil {
+				return nil, err
+			}
+			m[key] = val
+		}
+		return m, nil
+	}
+	return decodeGeneric(n)
+}
+
+func decodeGeneric(n *yaml.Node) (any, error) {
+	var val any
+	err := n.Decode(&val)
+	return val, err
+}
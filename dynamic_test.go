@@ -0,0 +1,100 @@
+package extyaml_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type dynamicConfig struct {
+	Extra  any
+	Attrs  map[string]any
+	Nested *any
+}
+
+func TestDynamic(t *testing.T) {
+	var nested any = []any{mustCIDR("10.2.0.0/16"), "s"}
+	in := dynamicConfig{
+		Extra: mustCIDR("10.0.0.0/8"),
+		Attrs: map[string]any{
+			"mac":    net.HardwareAddr{1, 2, 3, 4, 5, 6},
+			"subnet": mustCIDR("10.1.0.0/16"),
+			"port":   80,
+			"none":   nil,
+		},
+		Nested: &nested,
+	}
+	buf, err := extyaml.MarshalExt(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `extra: 10.0.0.0/8
+attrs:
+    mac: "01:02:03:04:05:06"
+    none: null
+    port: 80
+    subnet: 10.1.0.0/16
+nested:
+    - 10.2.0.0/16
+    - s
+`
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+	//without tag, dynamic values are decoded as plain YAML
+	out := new(dynamicConfig)
+	err = extyaml.UnmarshalExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Extra != "10.0.0.0/8" || out.Attrs["port"] != 80 || out.Attrs["none"] != nil || out.Nested == nil {
+		t.Fatalf("unexpected unmarshal result %+v", out)
+	}
+
+	doc := `extra: !ipnet 10.0.0.0/8
+attrs:
+  mac: !hardwareaddr 01:02:03:04:05:06
+  list: [!ipnet 10.1.0.0/16, 1]
+  plain: !ipnet 10.3.0.0/16
+nested: !ipnet 10.2.0.0/16
+`
+	out = new(dynamicConfig)
+	err = extyaml.UnmarshalExt([]byte(doc), out, extyaml.DecodeDynamicTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, ok := out.Extra.(net.IPNet); !ok || n.String() != "10.0.0.0/8" {
+		t.Fatalf("unexpected extra %#v", out.Extra)
+	}
+	if m, ok := out.Attrs["mac"].(net.HardwareAddr); !ok || m.String() != "01:02:03:04:05:06" {
+		t.Fatalf("unexpected mac %#v", out.Attrs["mac"])
+	}
+	if l, ok := out.Attrs["list"].([]any); !ok || len(l) != 2 || l[1] != 1 {
+		t.Fatalf("unexpected list %#v", out.Attrs["list"])
+	} else if n, ok := l[0].(net.IPNet); !ok || n.String() != "10.1.0.0/16" {
+		t.Fatalf("unexpected list item %#v", l[0])
+	}
+	if out.Nested == nil {
+		t.Fatal("expect non-nil nested")
+	} else if n, ok := (*out.Nested).(net.IPNet); !ok || n.String() != "10.2.0.0/16" {
+		t.Fatalf("unexpected nested %#v", *out.Nested)
+	}
+
+	//existing values are kept if not in the document, explicit null sets nil
+	err = extyaml.UnmarshalExt([]byte("attrs:\n  port: 8080\nextra: null\n"), out, extyaml.DecodeDynamicTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Extra != nil || out.Attrs["port"] != 8080 || out.Attrs["mac"] == nil || out.Nested == nil {
+		t.Fatalf("unexpected partial unmarshal result %+v", out)
+	}
+
+	//invalid value of tagged scalar
+	err = extyaml.UnmarshalExt([]byte("attrs:\n  a: !ipnet 10.0.0.0/33\n"), out, extyaml.DecodeDynamicTags())
+	var perr *extyaml.PosError
+	if !errors.As(err, &perr) || perr.Line != 2 {
+		t.Fatalf("expect error at line 2, got %v", err)
+	}
+}
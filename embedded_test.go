@@ -0,0 +1,151 @@
+package extyaml_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type EmbeddedWithMethod struct {
+	SubnetA net.IPNet
+}
+
+func (e EmbeddedWithMethod) Prefix() string {
+	return e.SubnetA.String()
+}
+
+type embeddedNonExport struct {
+	SubnetB net.IPNet
+	Port    int
+}
+
+type EmbeddedPointer struct {
+	SubnetC net.IPNet
+}
+
+type EmbeddedInline struct {
+	MacD net.HardwareAddr
+}
+
+type embeddedShapes struct {
+	EmbeddedWithMethod
+	embeddedNonExport `yaml:",inline"`
+	*EmbeddedPointer
+	EmbeddedInline `yaml:",inline"`
+	Extra          map[string]net.IPNet `yaml:",inline"`
+	Name           string
+}
+
+func mustCIDR(s string) net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return *n
+}
+
+func TestEmbedded(t *testing.T) {
+	in := embeddedShapes{
+		EmbeddedWithMethod: EmbeddedWithMethod{SubnetA: mustCIDR("10.0.0.0/8")},
+		embeddedNonExport:  embeddedNonExport{SubnetB: mustCIDR("10.1.0.0/16"), Port: 80},
+		EmbeddedPointer:    &EmbeddedPointer{SubnetC: mustCIDR("10.2.0.0/16")},
+		EmbeddedInline:     EmbeddedInline{MacD: net.HardwareAddr{1, 2, 3, 4, 5, 6}},
+		Extra:              map[string]net.IPNet{"x": mustCIDR("10.3.0.0/16")},
+		Name:               "n",
+	}
+	buf, err := extyaml.MarshalExt(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `embeddedwithmethod:
+    subneta: 10.0.0.0/8
+subnetb: 10.1.0.0/16
+port: 80
+embeddedpointer:
+    subnetc: 10.2.0.0/16
+macd: "01:02:03:04:05:06"
+name: "n"
+x: 10.3.0.0/16
+`
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+	out := new(embeddedShapes)
+	err = extyaml.UnmarshalExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Prefix() != "10.0.0.0/8" || out.SubnetB.String() != "10.1.0.0/16" || out.Port != 80 ||
+		out.EmbeddedPointer == nil || out.SubnetC.String() != "10.2.0.0/16" ||
+		out.MacD.String() != "01:02:03:04:05:06" || len(out.Extra) != 1 || out.Name != "n" {
+		t.Fatalf("unexpected unmarshal result %+v", out)
+	}
+
+	//nil embedded pointer
+	in.EmbeddedPointer = nil
+	buf, err = extyaml.MarshalExt(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out = new(embeddedShapes)
+	err = extyaml.UnmarshalExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.EmbeddedPointer != nil {
+		t.Fatalf("expect nil embedded pointer, got %+v", out.EmbeddedPointer)
+	}
+
+	//default value
+	def := in
+	def.embeddedNonExport.Port = 80
+	def.SubnetB = mustCIDR("192.168.0.0/16")
+	def.Extra = nil
+	def.Name = "n"
+	in.EmbeddedPointer = &EmbeddedPointer{SubnetC: mustCIDR("10.2.0.0/16")}
+	buf, err = extyaml.MarshalExtDefault(in, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `subnetb: 10.1.0.0/16
+embeddedpointer:
+    subnetc: 10.2.0.0/16
+x: 10.3.0.0/16
+`
+	if string(buf) != expected {
+		t.Fatalf("MarshalExtDefault result %v is different from expected %v", string(buf), expected)
+	}
+}
+
+type embeddedNonExportPointer struct {
+	SubnetE net.IPNet
+}
+
+type embeddedPointerShapes struct {
+	*embeddedNonExportPointer
+	Name string
+}
+
+func TestEmbeddedNonExportPointer(t *testing.T) {
+	in := embeddedPointerShapes{
+		embeddedNonExportPointer: &embeddedNonExportPointer{SubnetE: mustCIDR("10.4.0.0/16")},
+		Name:                     "n",
+	}
+	buf, err := extyaml.MarshalExt(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//skipped since it is not accessible
+	if string(buf) != "name: \"n\"\n" {
+		t.Fatalf("unexpected marshal result %v", string(buf))
+	}
+	out := new(embeddedPointerShapes)
+	err = extyaml.UnmarshalExt([]byte("name: m\nsubnete: 10.5.0.0/16\n"), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "m" || out.embeddedNonExportPointer != nil {
+		t.Fatalf("unexpected unmarshal result %+v", out)
+	}
+}
@@ -0,0 +1,171 @@
+package extyaml
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// EncryptedOpt is the ExtTag option of a field that is encrypted in marshaling output, e.g. `extyaml:"encrypted"`
+	EncryptedOpt = "encrypted"
+	// EncTag is the YAML tag of an encrypted value, e.g. "!enc <base64 ciphertext>"
+	EncTag = "!enc"
+)
+
+func init() {
+	RegisteredTypes.RegisterTagResolver(EncTag, RegisteredTypes.decryptNode)
+}
+
+// KeyProvider provides the AEAD cipher to encrypt and decrypt the values of encrypted fields
+type KeyProvider interface {
+	AEAD() (cipher.AEAD, error)
+}
+
+// SetKeyProvider sets the KeyProvider used to encrypt values of encrypted fields on marshaling,
+// and to decrypt "!enc" tagged values on unmarshalling
+func (reg *Registry) SetKeyProvider(p KeyProvider) {
+	reg.keyProvider = p
+}
+
+func (reg *Registry) aead() (cipher.AEAD, error) {
+	if reg.keyProvider == nil {
+		return nil, fmt.Errorf("no key provider is set")
+	}
+	return reg.keyProvider.AEAD()
+}
+
+// AESGCMKeyProvider is a KeyProvider of AES-GCM with a fixed key
+type AESGCMKeyProvider struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCMKeyProvider returns a AESGCMKeyProvider with key, key length must be 16, 24 or 32 bytes
+func NewAESGCMKeyProvider(key []byte) (*AESGCMKeyProvider, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCMKeyProvider{aead: aead}, nil
+}
+
+// LoadAESGCMKeyProvider returns a AESGCMKeyProvider with the key in file path,
+// the file contains either the base64 (standard encoding) encoded key or the raw key, optionally followed by a newline;
+// the content is decoded as base64 first, so a raw key that is also valid base64 must be stored base64 encoded
+func LoadAESGCMKeyProvider(path string) (*AESGCMKeyProvider, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(buf)))
+	if err != nil {
+		key = buf
+		if !validAESKeyLen(len(key)) {
+			key = bytes.TrimSuffix(bytes.TrimSuffix(key, []byte("\n")), []byte("\r"))
+		}
+		if !validAESKeyLen(len(key)) {
+			return nil, fmt.Errorf("%v: invalid key, not a base64 encoded or raw key of 16, 24 or 32 bytes", path)
+		}
+	}
+	p, err := NewAESGCMKeyProvider(key)
+	if err != nil {
+		return nil, fmt.Errorf("%v: %w", path, err)
+	}
+	return p, nil
+}
+
+func validAESKeyLen(n int) bool {
+	return n == 16 || n == 24 || n == 32
+}
+
+func (p *AESGCMKeyProvider) AEAD() (cipher.AEAD, error) {
+	return p.aead, nil
+}
+
+// encryptValues replaces every non-null scalar of encrypted fields in n with "!enc" tagged ciphertext,
+// the plaintext is the scalar in YAML, so that its type is kept
+func (reg *Registry) encryptValues(n *yaml.Node, t reflect.Type) error {
+	var aead cipher.AEAD
+	var encrypt func(v *yaml.Node) error
+	encrypt = func(v *yaml.Node) error {
+		switch v.Kind {
+		case yaml.ScalarNode:
+			if v.ShortTag() == "!!null" {
+				return nil
+			}
+		case yaml.MappingNode:
+			for i := 1; i < len(v.Content); i += 2 {
+				if err := encrypt(v.Content[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		case yaml.SequenceNode:
+			for _, c := range v.Content {
+				if err := encrypt(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		default:
+			return nil
+		}
+		if aead == nil {
+			var err error
+			aead, err = reg.aead()
+			if err != nil {
+				return err
+			}
+		}
+		plain, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		sealed := aead.Seal(nonce, nonce, bytes.TrimSuffix(plain, []byte("\n")), nil)
+		*v = yaml.Node{Kind: yaml.ScalarNode, Tag: EncTag, Value: base64.StdEncoding.EncodeToString(sealed)}
+		return nil
+	}
+	return fieldValues(n, t, EncryptedOpt, encrypt)
+}
+
+// decryptNode is the TagResolver of EncTag
+func (reg *Registry) decryptNode(n *yaml.Node) (*yaml.Node, error) {
+	aead, err := reg.aead()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := base64.StdEncoding.DecodeString(n.Value)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf) < aead.NonceSize() {
+		return nil, fmt.Errorf("ciphertext is too short")
+	}
+	plain, err := aead.Open(nil, buf[:aead.NonceSize()], buf[aead.NonceSize():], nil)
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(plain, &doc) != nil || len(doc.Content) != 1 || doc.Content[0].Kind != yaml.ScalarNode {
+		//not a YAML scalar, use the plaintext as it is
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: string(plain)}, nil
+	}
+	r := doc.Content[0]
+	r.Line, r.Column = 0, 0
+	return r, nil
+}
@@ -0,0 +1,157 @@
+package extyaml_test
+
+import (
+	"encoding/base64"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type encryptConfig struct {
+	User     string
+	Password string            `extyaml:"encrypted"`
+	Port     int               `extyaml:"encrypted"`
+	Subnet   net.IPNet         `extyaml:"encrypted"`
+	Tokens   []string          `extyaml:"encrypted"`
+	Empty    string            `extyaml:"encrypted"`
+	Key      *string           `extyaml:"encrypted"`
+	Both     string            `extyaml:"encrypted,secret"`
+	Numbers  map[string]string `extyaml:"encrypted"`
+}
+
+func TestEncrypted(t *testing.T) {
+	cfg := &encryptConfig{
+		User:     "u",
+		Password: "p1",
+		Port:     8080,
+		Subnet:   mustCIDR("10.0.0.0/24"),
+		Tokens:   []string{"t1", "null"},
+		Both:     "b",
+		Numbers:  map[string]string{"a": "123"},
+	}
+	_, err := extyaml.MarshalExt(cfg)
+	if err == nil || !strings.Contains(err.Error(), "no key provider") {
+		t.Fatalf("expect no key provider error, got %v", err)
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "key")
+	err = os.WriteFile(keyFile, []byte(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))+"\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := extyaml.LoadAESGCMKeyProvider(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extyaml.RegisteredTypes.SetKeyProvider(p)
+	defer extyaml.RegisteredTypes.SetKeyProvider(nil)
+
+	buf, err := extyaml.MarshalExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := string(buf)
+	//ciphertext is random base64, only check the plaintext part of the output
+	plain := regexp.MustCompile(`!enc \S+`).ReplaceAllString(s, "!enc")
+	if strings.Contains(plain, "p1") || strings.Contains(plain, "10.0.0.0") || strings.Contains(plain, "8080") ||
+		strings.Count(s, "!enc ") != 7 || !strings.Contains(s, "user: u") || !strings.Contains(s, "both: '******'") {
+		t.Fatalf("unexpected output\n%v", s)
+	}
+
+	buf, err = extyaml.MarshalExtUnredacted(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(encryptConfig)
+	err = extyaml.UnmarshalExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Password != "p1" || out.Port != 8080 || out.Subnet.String() != "10.0.0.0/24" || out.Key != nil ||
+		len(out.Tokens) != 2 || out.Tokens[1] != "null" || out.Both != "b" || out.Numbers["a"] != "123" {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	//plaintext is accepted for encrypted field
+	out = new(encryptConfig)
+	err = extyaml.UnmarshalExt([]byte("password: plain\n"), out)
+	if err != nil || out.Password != "plain" {
+		t.Fatalf("unexpected result %+v, %v", out, err)
+	}
+
+	other, err := extyaml.NewAESGCMKeyProvider([]byte("fedcba9876543210"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	extyaml.RegisteredTypes.SetKeyProvider(other)
+	err = extyaml.UnmarshalExt(buf, new(encryptConfig))
+	if err == nil || !strings.Contains(err.Error(), "failed to resolve !enc") {
+		t.Fatalf("expect decryption error, got %v", err)
+	}
+
+	//unchanged encrypted field is omitted by MarshalExtDefault
+	buf, err = extyaml.MarshalExtDefault(&encryptConfig{User: "x", Password: "p"}, &encryptConfig{Password: "p"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "user: x\n" {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+}
+
+func TestLoadAESGCMKeyProvider(t *testing.T) {
+	//a raw key that is not valid base64, and a base64 encoded key that is 32 bytes long
+	raw := []byte("0123456789abcdef0123456789abcde!")
+	encoded := []byte(base64.StdEncoding.EncodeToString([]byte("0123456789abcdef01234567")))
+	ref, err := extyaml.NewAESGCMKeyProvider([]byte("0123456789abcdef01234567"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := extyaml.NewAESGCMKeyProvider(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []struct {
+		content []byte
+		expect  *extyaml.AESGCMKeyProvider
+	}{
+		{raw, other},
+		{append(raw, '\n'), other},
+		{encoded, ref},
+		{append(encoded, '\n'), ref},
+	} {
+		keyFile := filepath.Join(t.TempDir(), "key")
+		if err := os.WriteFile(keyFile, c.content, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		p, err := extyaml.LoadAESGCMKeyProvider(keyFile)
+		if err != nil {
+			t.Fatalf("key file %q: %v", c.content, err)
+		}
+		//the loaded key decrypts what the expected key encrypts
+		extyaml.RegisteredTypes.SetKeyProvider(c.expect)
+		buf, err := extyaml.MarshalExt(&encryptConfig{Password: "p"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		extyaml.RegisteredTypes.SetKeyProvider(p)
+		out := new(encryptConfig)
+		err = extyaml.UnmarshalExt(buf, out)
+		extyaml.RegisteredTypes.SetKeyProvider(nil)
+		if err != nil || out.Password != "p" {
+			t.Fatalf("key file %q: unexpected result %+v, %v", c.content, out, err)
+		}
+	}
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("short\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := extyaml.LoadAESGCMKeyProvider(keyFile); err == nil || !strings.Contains(err.Error(), "invalid key") {
+		t.Fatalf("expect invalid key error, got %v", err)
+	}
+}
@@ -0,0 +1,141 @@
+package extyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envName returns the environment variable name of YAML key k, e.g. "TIME_OUT" for "time-out"
+func envName(k string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, k)
+}
+
+// envPrefix returns prefix followed by "_", empty if prefix is empty
+func envPrefix(prefix string) string {
+	if prefix == "" || strings.HasSuffix(prefix, "_") {
+		return prefix
+	}
+	return prefix + "_"
+}
+
+// matchEnv matches name against type t, p is the path of t; name is the variable name without prefix and the keys before t
+func matchEnv(t reflect.Type, name, p string) (string, bool) {
+	if name == "" {
+		return p, true
+	}
+	t = indirectType(t)
+	if isCodecType(t) {
+		return "", false
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		for _, f := range yamlFields(t) {
+			n := envName(f.key)
+			var rest string
+			switch {
+			case name == n:
+			case strings.HasPrefix(name, n+"_"):
+				rest = name[len(n)+1:]
+			default:
+				continue
+			}
+			if m, ok := matchEnv(f.field.Type, rest, appendKey(p, f.key)); ok {
+				return m, true
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		token, rest, _ := strings.Cut(name, "_")
+		i, err := strconv.Atoi(token)
+		if err != nil || i < 0 || strconv.Itoa(i) != token {
+			return "", false
+		}
+		return matchEnv(t.Elem(), rest, appendIndex(p, i))
+	case reflect.Map:
+		//map key is lower case, the rest of the name is the key if the value is a leaf
+		if isLeafType(t.Elem()) {
+			return appendKey(p, strings.ToLower(name)), true
+		}
+		token, rest, _ := strings.Cut(name, "_")
+		return matchEnv(t.Elem(), rest, appendKey(p, strings.ToLower(token)))
+	}
+	return "", false
+}
+
+// UnmarshalEnv sets the fields of the struct out points to from environ (in format of "key=value", e.g. os.Environ());
+// a variable name is prefix and the YAML path of the field in upper case joined by "_", with any character other than
+// letters and digits replaced by "_", e.g. "APP_SERVERS_0_SUBNET" for "servers[0].subnet" with prefix "APP";
+// a map key is the lower case of the rest of the name; values are parsed via registered codecs the same way as SetPath.
+// a slice with any variable is replaced, its elements must be set from index 0 without gaps; variables with the prefix
+// that don't match any field are ignored, see EnvNames for the recognized names.
+func UnmarshalEnv(out any, prefix string, environ []string) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("the object to unmarshal is not a pointer to struct")
+	}
+	prefix = envPrefix(prefix)
+	var list []pathValue
+	for _, e := range environ {
+		name, value, ok := strings.Cut(e, "=")
+		if !ok || !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+		if p, ok := matchEnv(v.Elem().Type(), name[len(prefix):], ""); ok {
+			list = append(list, pathValue{name: name, path: p, value: value})
+		}
+	}
+	return setPaths(out, list)
+}
+
+// EnvNames returns the environment variable names recognized by UnmarshalEnv for the struct cfg points to, in field order;
+// "<N>" is the placeholder of a slice or array index, "<KEY>" is the placeholder of a map key.
+// a slice, array or map is listed both as a whole (parsed as YAML) and by element; fields of a recursive type are listed once.
+func EnvNames(prefix string, cfg any) []string {
+	t := indirectType(reflect.TypeOf(cfg))
+	var r []string
+	envNames(t, strings.TrimSuffix(envPrefix(prefix), "_"), map[reflect.Type]bool{}, &r)
+	return r
+}
+
+func envNames(t reflect.Type, name string, visiting map[reflect.Type]bool, r *[]string) {
+	join := func(k string) string {
+		if name == "" {
+			return k
+		}
+		return name + "_" + k
+	}
+	t = indirectType(t)
+	if isCodecType(t) {
+		*r = append(*r, name)
+		return
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if visiting[t] {
+			return
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+		for _, f := range yamlFields(t) {
+			envNames(f.field.Type, join(envName(f.key)), visiting, r)
+		}
+	case reflect.Slice, reflect.Array:
+		*r = append(*r, name)
+		envNames(t.Elem(), join("<N>"), visiting, r)
+	case reflect.Map:
+		*r = append(*r, name)
+		envNames(t.Elem(), join("<KEY>"), visiting, r)
+	case reflect.Func, reflect.Chan:
+	default:
+		*r = append(*r, name)
+	}
+}
@@ -0,0 +1,84 @@
+package extyaml_test
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type envServer struct {
+	Subnet  net.IPNet
+	Timeout time.Duration `yaml:"time-out"`
+}
+
+type envConfig struct {
+	Name    string
+	Time    time.Duration
+	Servers []envServer
+	Labels  map[string]int
+	Mac     *net.HardwareAddr
+	Hidden  string `skipyamlmarshal:""`
+}
+
+func TestUnmarshalEnv(t *testing.T) {
+	cfg := &envConfig{
+		Name:    "file",
+		Servers: []envServer{{}, {}, {}},
+	}
+	err := extyaml.UnmarshalEnv(cfg, "APP", []string{
+		"APP_SERVERS_1_SUBNET=10.0.1.0/24",
+		"APP_SERVERS_0_SUBNET=10.0.0.0/24",
+		"APP_SERVERS_0_TIME_OUT=5s",
+		"APP_TIME=1m",
+		"APP_LABELS_A_B=1",
+		"APP_MAC=11:22:33:44:55:66",
+		"APP_HIDDEN=x",
+		"APP_UNKNOWN=x",
+		"OTHER_NAME=x",
+		"PATH=/bin",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "file" || cfg.Time != time.Minute || cfg.Hidden != "" || cfg.Mac.String() != "11:22:33:44:55:66" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	if len(cfg.Servers) != 2 || cfg.Servers[0].Timeout != 5*time.Second || cfg.Servers[1].Subnet.String() != "10.0.1.0/24" {
+		t.Fatalf("unexpected servers %+v", cfg.Servers)
+	}
+	if !reflect.DeepEqual(cfg.Labels, map[string]int{"a_b": 1}) {
+		t.Fatalf("unexpected labels %v", cfg.Labels)
+	}
+
+	err = extyaml.UnmarshalEnv(cfg, "APP_", []string{"APP_LABELS={x: 2}", "APP_SERVERS=[{subnet: 10.0.2.0/24}]"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Labels, map[string]int{"x": 2}) || len(cfg.Servers) != 1 || cfg.Servers[0].Subnet.String() != "10.0.2.0/24" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+
+	for _, c := range []struct {
+		env, err string
+	}{
+		{"APP_SERVERS_0_SUBNET=10.0.0.300/24", "APP_SERVERS_0_SUBNET: servers[0].subnet"},
+		{"APP_SERVERS_1_SUBNET=10.0.0.0/24", "invalid index 1"},
+		{"APP_TIME=x", "APP_TIME: time"},
+	} {
+		err := extyaml.UnmarshalEnv(new(envConfig), "APP", []string{c.env})
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%v: expect error %q, got %v", c.env, c.err, err)
+		}
+	}
+
+	names := extyaml.EnvNames("APP", new(envConfig))
+	expect := []string{"APP_NAME", "APP_TIME", "APP_SERVERS", "APP_SERVERS_<N>_SUBNET", "APP_SERVERS_<N>_TIME_OUT",
+		"APP_LABELS", "APP_LABELS_<KEY>", "APP_MAC"}
+	if !reflect.DeepEqual(names, expect) {
+		t.Fatalf("unexpected names %v", names)
+	}
+}
@@ -2,6 +2,7 @@ package extyaml
 
 import (
 	"encoding"
+	"errors"
 	"fmt"
 	"log"
 	"reflect"
@@ -24,9 +25,95 @@ const (
 	//the first letter of the prefix must be lower case to make it non-export
 	SkipNamingPrefix = "skippedExtYAMLField"
 	SkipTag          = "skipyamlmarshal"
+	//the first letter of the prefix must be upper case to make it export
+	EmbeddedNamingPrefix = "EmbeddedExtYAMLField"
 )
 
+// mirrorPkgPath is the PkgPath of non-export fields created in converted struct types
+var mirrorPkgPath = reflect.TypeOf(registeredType{}).PkgPath()
+
+// isUnexportedEmbedded returns true if field is an embedded non-pointer struct of non-export type,
+// its exported fields are promoted and accessible via reflect;
+// an embedded pointer to non-export struct is not, since the pointer can't be allocated via reflect, it is skipped like a non-export field
+func isUnexportedEmbedded(field reflect.StructField) bool {
+	if !field.Anonymous || field.IsExported() || field.Type.Kind() != reflect.Struct {
+		return false
+	}
+	_, skip := field.Tag.Lookup(SkipTag)
+	return !skip
+}
+
+// exportedCopy returns a copy of struct v that only has the export fields of v,
+// it is used to read an embedded non-export struct, which itself is not accessible
+func exportedCopy(v reflect.Value) reflect.Value {
+	r := reflect.New(v.Type()).Elem()
+	copyExported(r, v)
+	return r
+}
+
+func copyExported(dst, src reflect.Value) {
+	for i := 0; i < src.NumField(); i++ {
+		field := src.Type().Field(i)
+		if isUnexportedEmbedded(field) {
+			copyExported(dst.Field(i), src.Field(i))
+			continue
+		}
+		if field.IsExported() {
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// recursiveExt is the placeholder in converted type for a recursive type, since converted type must be finite;
+// the orig value is converted when marshaling/unmarshalling the placeholder
+type recursiveExt struct {
+	//orig is the pointer to a copy of orig value, set by translateStructInline
+	orig reflect.Value
+	//o is the options of marshaling
+	o *options
+	//node is set by UnmarshalYAML
+	node *yaml.Node
+}
+
+var recursiveExtType = reflect.TypeOf(recursiveExt{})
+
+func (ext recursiveExt) MarshalYAML() (interface{}, error) {
+	if !ext.orig.IsValid() {
+		return nil, nil
+	}
+	o := ext.o
+	if o == nil {
+		o = newOptions(nil)
+	}
+	return marshalNode(ext.orig.Interface(), o)
+}
+
+func (ext *recursiveExt) UnmarshalYAML(value *yaml.Node) error {
+	ext.node = value
+	return nil
+}
+
+// translateError is raised via panic in translateStructInline, and recovered by recoverTranslateError
+type translateError struct {
+	err error
+}
+
+func recoverTranslateError(err *error) {
+	if r := recover(); r != nil {
+		if e, ok := r.(translateError); ok {
+			*err = e.err
+			return
+		}
+		panic(r)
+	}
+}
+
 func convertStructType(t reflect.Type) reflect.Type {
+	return convertType(t, make(map[reflect.Type]bool))
+}
+
+// convertType returns the converted type of t, visiting is the types being converted, used to detect recursive type
+func convertType(t reflect.Type, visiting map[reflect.Type]bool) reflect.Type {
 	isPtr := false
 	if t.Kind() == reflect.Pointer {
 		//a real pointer, not pointer like type like slice
@@ -34,6 +121,16 @@ func convertStructType(t reflect.Type) reflect.Type {
 		//if it is pointer, then also return a pointer type
 		isPtr = true
 	}
+	if t == emptyInterfaceInt {
+		//the type of value is only known at runtime
+		return dynamicExtPtrType
+	}
+	if t == yamlNodeType {
+		if isPtr {
+			return yamlNodePtrType
+		}
+		return t
+	}
 	if RegisteredTypes.isSupportedType(t, true) {
 		//input is a supported type
 		if isPtr {
@@ -49,30 +146,59 @@ func convertStructType(t reflect.Type) reflect.Type {
 		return t
 	}
 
+	switch t.Kind() {
+	case reflect.Array, reflect.Slice, reflect.Map, reflect.Struct:
+		if visiting[t] {
+			//a recursive type
+			if isPtr {
+				return reflect.PointerTo(recursiveExtType)
+			}
+			return recursiveExtType
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+	}
 	switch t.Kind() {
 	case reflect.Array:
 		if isPtr {
-			return reflect.PointerTo(reflect.ArrayOf(t.Len(), convertStructType(t.Elem())))
+			return reflect.PointerTo(reflect.ArrayOf(t.Len(), convertType(t.Elem(), visiting)))
 		}
-		return reflect.ArrayOf(t.Len(), convertStructType(t.Elem()))
+		return reflect.ArrayOf(t.Len(), convertType(t.Elem(), visiting))
 	case reflect.Slice:
 		if isPtr {
-			return reflect.PointerTo(reflect.SliceOf(convertStructType(t.Elem())))
+			return reflect.PointerTo(reflect.SliceOf(convertType(t.Elem(), visiting)))
 		}
-		return reflect.SliceOf(convertStructType(t.Elem()))
+		return reflect.SliceOf(convertType(t.Elem(), visiting))
 	case reflect.Map:
 		if isPtr {
-			return reflect.PointerTo(reflect.MapOf(convertStructType(t.Key()), convertStructType(t.Elem())))
+			return reflect.PointerTo(reflect.MapOf(convertType(t.Key(), visiting), convertType(t.Elem(), visiting)))
 		}
-		return reflect.MapOf(convertStructType(t.Key()), convertStructType(t.Elem()))
+		return reflect.MapOf(convertType(t.Key(), visiting), convertType(t.Elem(), visiting))
 	case reflect.Struct:
 		var list = []reflect.StructField{}
 		for i := 0; i < t.NumField(); i++ {
 			field := t.Field(i)
 			// fmt.Printf("======%v anonymous %v isexport %v, %v\n", field.Name, field.Anonymous, field.IsExported(), field.PkgPath)
+			if isUnexportedEmbedded(field) {
+				//use an export field so its promoted fields could be marshaled
+				list = append(list, reflect.StructField{
+					Name:  EmbeddedNamingPrefix + field.Name,
+					Type:  convertType(field.Type, visiting),
+					Tag:   RegisteredTypes.mirrorTag(field),
+					Index: field.Index,
+				})
+				continue
+			}
 			if !field.IsExported() {
-				//a non-export field
-				list = append(list, field)
+				//a non-export field, StructOf doesn't allow a non-export anonymous field;
+				//all non-export fields use mirrorPkgPath since StructOf doesn't allow different PkgPath
+				list = append(list, reflect.StructField{
+					Name:    field.Name,
+					Type:    field.Type,
+					PkgPath: mirrorPkgPath,
+					Tag:     field.Tag,
+					Index:   field.Index,
+				})
 				continue
 			}
 
@@ -81,21 +207,31 @@ func convertStructType(t reflect.Type) reflect.Type {
 				list = append(list, reflect.StructField{
 					Name:    SkipNamingPrefix + field.Name,
 					Type:    field.Type,
-					PkgPath: t.PkgPath(),
+					PkgPath: mirrorPkgPath,
 					Tag:     field.Tag,
 					Index:   field.Index,
 				})
 				continue
 			}
+			if isRemainField(field) && (field.Type == remainMapType || field.Type == yamlNodePtrType) {
+				//unknown keys are kept in a mapping node, in their original order
+				list = append(list, reflect.StructField{
+					Name:  field.Name,
+					Type:  yamlNodeType,
+					Tag:   RegisteredTypes.mirrorTag(field),
+					Index: field.Index,
+				})
+				continue
+			}
 			// pkgPath := field.PkgPath
 			// if field.Anonymous {
 			// 	pkgPath = t.PkgPath()
 			// }
 			list = append(list, reflect.StructField{
 				Name:    field.Name,
-				Type:    convertStructType(field.Type),
+				Type:    convertType(field.Type, visiting),
 				PkgPath: field.PkgPath,
-				Tag:     field.Tag,
+				Tag:     RegisteredTypes.mirrorTag(field),
 				Index:   field.Index,
 			})
 		}
@@ -120,7 +256,7 @@ var (
 
 // translateStructInline out = in (convert to out's type), out MUST be a pointer
 // NOTE: the tag here is for future use
-func translateStructInline(in, out any, tag reflect.StructTag, toExt bool) {
+func translateStructInline(in, out any, tag reflect.StructTag, toExt bool, o *options) {
 	//setFunc set a=b,  b is type T, a could be either *T or **T,
 	setFunc := func(a, b reflect.Value) {
 		if !b.IsValid() {
@@ -151,6 +287,14 @@ func translateStructInline(in, out any, tag reflect.StructTag, toExt bool) {
 	if rV.Kind() != reflect.Pointer {
 		log.Fatalf("%v is not a pointer, but a %v", out, rV.Kind())
 	}
+	if translateDynamic(in, rV, toExt, o) || translateRemain(in, rV, toExt) {
+		return
+	}
+	if in == nil {
+		//nil interface value
+		wipeFunc(out)
+		return
+	}
 	inT := reflect.TypeOf(in)
 	inV := reflect.ValueOf(in)
 	isNil := false
@@ -171,6 +315,26 @@ func translateStructInline(in, out any, tag reflect.StructTag, toExt bool) {
 			rV.Elem().Set(reflect.New(rV.Type().Elem().Elem()))
 		}
 	}
+	target := rV.Elem()
+	if target.Kind() == reflect.Pointer {
+		target = target.Elem()
+	}
+	if target.Type() == recursiveExtType && inT != recursiveExtType {
+		//placeholder of recursive type, keep a copy of orig value
+		c := reflect.New(inT)
+		c.Elem().Set(inV)
+		*target.Addr().Interface().(*recursiveExt) = recursiveExt{orig: c, o: o}
+		return
+	}
+	if inT == recursiveExtType && target.Type() != recursiveExtType {
+		if n := inV.Interface().(recursiveExt).node; n != nil {
+			err := decodeNode(n, target.Addr().Interface(), o)
+			if err != nil {
+				panic(translateError{err: err})
+			}
+		}
+		return
+	}
 
 	if RegisteredTypes.isSupportedType(inT, toExt) {
 		//input is a supported type
@@ -188,6 +352,10 @@ func translateStructInline(in, out any, tag reflect.StructTag, toExt bool) {
 			return
 		}
 	}
+	if inT == yamlNodeType {
+		setFunc(rV, inV)
+		return
+	}
 	//check if there is supported marshaling method
 	if toExt {
 		if inT.Implements(textMarshalerInt) || inT.Implements(yamlMarshalerInt) {
@@ -218,18 +386,22 @@ func translateStructInline(in, out any, tag reflect.StructTag, toExt bool) {
 			case reflect.Array:
 				if inV.IsValid() {
 					for i := 0; i < inV.Len(); i++ {
-						translateStructInline(inV.Index(i).Interface(), rV.Elem().Index(i).Addr().Interface(), tag, toExt)
+						translateStructInline(inV.Index(i).Interface(), rV.Elem().Index(i).Addr().Interface(), tag, toExt, o)
 					}
 				}
 				return
 			case reflect.Slice:
+				if !toExt && o.merging && (inV.Len() > 0 || rV.Elem().Len() > 0) {
+					//the merged sequence replaces the whole slice, existing elements are not reused
+					rV.Elem().Set(reflect.MakeSlice(rV.Type().Elem(), 0, inV.Len()))
+				}
 				for i := 0; i < inV.Len(); i++ {
 					if i <= rV.Elem().Len()-1 {
-						translateStructInline(inV.Index(i).Interface(), rV.Elem().Index(i).Addr().Interface(), tag, toExt)
+						translateStructInline(inV.Index(i).Interface(), rV.Elem().Index(i).Addr().Interface(), tag, toExt, o)
 					} else {
 						//the current rV len is smaller than input
 						newElement := reflect.New(rV.Type().Elem().Elem()).Elem()
-						translateStructInline(inV.Index(i).Interface(), newElement.Addr().Interface(), tag, toExt)
+						translateStructInline(inV.Index(i).Interface(), newElement.Addr().Interface(), tag, toExt, o)
 						rV.Elem().Set(reflect.Append(rV.Elem(), newElement))
 					}
 
@@ -238,14 +410,15 @@ func translateStructInline(in, out any, tag reflect.StructTag, toExt bool) {
 			case reflect.Map:
 				//in is map
 				iter := inV.MapRange()
-				if rV.Elem().IsNil() {
+				//a merged empty mapping keeps the existing nil map, since a nil map is marshaled as empty mapping
+				if rV.Elem().IsNil() && !(!toExt && o.merging && inV.Len() == 0) {
 					rV.Elem().Set(reflect.MakeMap(rV.Type().Elem()))
 				}
 				for iter.Next() {
 					newkey := reflect.New(rV.Type().Elem().Key())
 					newval := reflect.New(rV.Type().Elem().Elem())
-					translateStructInline(iter.Key().Interface(), newkey.Interface(), tag, toExt)
-					translateStructInline(iter.Value().Interface(), newval.Interface(), tag, toExt)
+					translateStructInline(iter.Key().Interface(), newkey.Interface(), tag, toExt, o)
+					translateStructInline(iter.Value().Interface(), newval.Interface(), tag, toExt, o)
 					rV.Elem().SetMapIndex(newkey.Elem(), newval.Elem())
 				}
 				return
@@ -260,25 +433,40 @@ func translateStructInline(in, out any, tag reflect.StructTag, toExt bool) {
 		for rV.Kind() == reflect.Pointer {
 			rV = rV.Elem()
 		}
+		translateStructFields(inV, rV, toExt, o)
+	}
+}
 
-		for i := 0; i < inT.NumField(); i++ {
-			// fmt.Println("waling field", inT.Field(i).Name)
-			if !inT.Field(i).IsExported() {
-				continue
-			}
-			toChkField := inT.Field(i)
-			if toExt {
-				toChkField = rV.Type().Field(i)
-			}
-			if _, exists := toChkField.Tag.Lookup(SkipTag); exists {
-				continue
-			}
-			fieldRint := rV.Field(i).Addr().Interface()
-			// if rV.Field(i).Kind() == reflect.Ptr {
-			// 	fieldRint = rV.Field(i).Interface()
-			// }
-			translateStructInline(inV.Field(i).Interface(), fieldRint, inT.Field(i).Tag, toExt)
+// translateStructFields translates each field of struct inV into the corresponding field of struct rV
+func translateStructFields(inV, rV reflect.Value, toExt bool, o *options) {
+	inT := inV.Type()
+	for i := 0; i < inT.NumField(); i++ {
+		// fmt.Println("waling field", inT.Field(i).Name)
+		toChkField := inT.Field(i)
+		if toExt {
+			toChkField = rV.Type().Field(i)
 		}
+		if _, exists := toChkField.Tag.Lookup(SkipTag); exists {
+			continue
+		}
+		if isUnexportedEmbedded(inT.Field(i)) || isUnexportedEmbedded(rV.Type().Field(i)) {
+			//the embedded struct itself is not accessible, but its export fields are
+			translateStructFields(inV.Field(i), rV.Field(i), toExt, o)
+			continue
+		}
+		if !inT.Field(i).IsExported() {
+			continue
+		}
+		fieldRint := rV.Field(i).Addr().Interface()
+		// if rV.Field(i).Kind() == reflect.Ptr {
+		// 	fieldRint = rV.Field(i).Interface()
+		// }
+		translateStructInline(inV.Field(i).Interface(), fieldRint, inT.Field(i).Tag, toExt, o)
+	}
+	if toExt {
+		dropKnownKeys(rV, inT)
+	} else {
+		dropKnownKeys(rV, rV.Type())
 	}
 }
 
@@ -287,35 +475,245 @@ type PostUnmarshal interface {
 	PostUnmarshal() error
 }
 
+// PosError is an error with its position in the source YAML document
+type PosError struct {
+	//Source is the name of source document, empty if unknown
+	Source       string
+	Line, Column int
+	Err          error
+	node         *yaml.Node
+}
+
+func newPosError(n *yaml.Node, err error) *PosError {
+	return &PosError{Line: n.Line, Column: n.Column, Err: err, node: n}
+}
+
+func (e *PosError) Error() string {
+	if e.Source == "" {
+		return fmt.Sprintf("line %d, column %d: %v", e.Line, e.Column, e.Err)
+	}
+	return fmt.Sprintf("%s:%d:%d: %v", e.Source, e.Line, e.Column, e.Err)
+}
+
+func (e *PosError) Unwrap() error {
+	return e.Err
+}
+
 // UnmarshalExt unmarshal YAML bytes buf into out, out must be a pointer.
 // out.PostUnmarshal() gets called at the end if out implements PostUnmarshal interface
-func UnmarshalExt(buf []byte, out any) error {
-	if reflect.TypeOf(out).Kind() != reflect.Pointer {
-		return fmt.Errorf("the object unmarhsal into is not a pointer")
+func UnmarshalExt(buf []byte, out any, opts ...Option) error {
+	var doc yaml.Node
+	err := yaml.Unmarshal(buf, &doc)
+	if err != nil {
+		return err
 	}
-	exType := convertStructType(reflect.TypeOf(out))
-	exType = exType.Elem()
-	extVal := reflect.New(exType) //this is needed to avoid pointer to pointer
-	translateStructInline(out, extVal.Interface(), "", true)
-	err := yaml.Unmarshal(buf, extVal.Interface())
+	o := newOptions(opts)
+	err = unmarshalNode(&doc, out, o)
 	if err != nil {
+		var perr *PosError
+		if errors.As(err, &perr) && perr.Source == "" {
+			perr.Source = o.source
+		}
 		return err
 	}
-	translateStructInline(extVal.Interface(), out, "", false)
+	return postUnmarshal(out)
+}
+
+// postUnmarshal calls out.PostUnmarshal() if out implements PostUnmarshal interface
+func postUnmarshal(out any) error {
 	if newout, ok := out.(PostUnmarshal); ok {
 		return newout.PostUnmarshal()
 	}
 	return nil
 }
 
-// MarshalExt marshal in into YAML bytes
-func MarshalExt(in any) ([]byte, error) {
+// unmarshalNode unmarshal parsed YAML document doc into out, without calling PostUnmarshal
+func unmarshalNode(doc *yaml.Node, out any, o *options) error {
+	if reflect.TypeOf(out).Kind() != reflect.Pointer {
+		return fmt.Errorf("the object unmarhsal into is not a pointer")
+	}
+	if doc.Kind != 0 {
+		err := RegisteredTypes.foldKeys(doc, reflect.TypeOf(out))
+		if err != nil {
+			return err
+		}
+		err = applyAliases(doc, reflect.TypeOf(out), o)
+		if err != nil {
+			return err
+		}
+		//after the keys are normalized, so that the type of a resolved value is known
+		err = RegisteredTypes.resolveTags(doc, reflect.TypeOf(out))
+		if err != nil {
+			return err
+		}
+	}
+	if o.metadata != nil {
+		o.metadata.record(doc, o)
+	}
+	var resets [][]*yaml.Node
+	if doc.Kind != 0 {
+		err := collectRemain(doc, reflect.TypeOf(out))
+		if err != nil {
+			return err
+		}
+		if o.merging {
+			resets = collectResets(doc, nil)
+			clean(doc)
+		}
+	}
+	err := decodeNode(doc, out, o)
+	if err != nil {
+		return err
+	}
+	for _, p := range resets {
+		err = reset(reflect.ValueOf(out), p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeNode decodes YAML node n into out via converted type, out must be a pointer
+func decodeNode(n *yaml.Node, out any, o *options) (err error) {
+	exType := convertStructType(reflect.TypeOf(out))
+	if exType != dynamicExtPtrType {
+		exType = exType.Elem()
+	}
+	extVal := reflect.New(exType) //this is needed to avoid pointer to pointer
+	if !o.onlyPresent {
+		translateStructInline(out, extVal.Interface(), "", true, o)
+	}
+	if n.Kind != 0 {
+		//an empty document leaves out untouched
+		err = n.Decode(extVal.Interface())
+		if err != nil {
+			var terr *yaml.TypeError
+			if errors.As(err, &terr) {
+				if en := typeErrorNode(n, reflect.TypeOf(out)); en != nil {
+					return newPosError(en, err)
+				}
+			}
+			return err
+		}
+	}
+	defer recoverTranslateError(&err)
+	if o.onlyPresent {
+		if n.Kind != 0 {
+			applyPresent(n, extVal.Elem(), reflect.ValueOf(out).Elem(), o)
+		}
+		return nil
+	}
+	if exType == dynamicExtPtrType {
+		//out is a pointer to interface{}
+		extVal = extVal.Elem()
+	}
+	translateStructInline(extVal.Interface(), out, "", false, o)
+	return nil
+}
+
+// typeErrorNode returns the node in n that causes a yaml.TypeError when decoding n into Go type t, nil if it is not found;
+// since yaml.TypeError doesn't have the node, each node is decoded alone into the converted type of its Go type,
+// the innermost failed node is returned, the first one in document order if there are more.
+func typeErrorNode(n *yaml.Node, t reflect.Type) *yaml.Node {
+	types := make(map[*yaml.Node]reflect.Type)
+	walkNode(n, t, func(c *yaml.Node, ct reflect.Type) error {
+		if ct != nil {
+			types[c] = ct
+		}
+		return nil
+	})
+	var find func(c *yaml.Node) *yaml.Node
+	find = func(c *yaml.Node) *yaml.Node {
+		ct, ok := types[c]
+		if !ok {
+			return nil
+		}
+		var terr *yaml.TypeError
+		if err := c.Decode(reflect.New(convertStructType(ct)).Interface()); !errors.As(err, &terr) {
+			return nil
+		}
+		for _, child := range c.Content {
+			if r := find(child); r != nil {
+				return r
+			}
+		}
+		return c
+	}
+	roots := []*yaml.Node{n}
+	if n.Kind == yaml.DocumentNode {
+		roots = n.Content
+	}
+	for _, c := range roots {
+		if r := find(c); r != nil {
+			return r
+		}
+	}
+	return nil
+}
+
+// marshalNode encodes in into YAML node via converted type
+func marshalNode(in any, o *options) (*yaml.Node, error) {
 	inV := reflect.ValueOf(in)
 	if inV.Kind() == reflect.Pointer {
 		inV = inV.Elem()
 	}
 	newType := convertStructType(reflect.TypeOf(inV.Interface()))
 	newVal := reflect.New(newType)
-	translateStructInline(inV.Interface(), newVal.Interface(), "", true)
-	return yaml.Marshal(newVal.Interface())
+	translateStructInline(inV.Interface(), newVal.Interface(), "", true, o)
+	n := new(yaml.Node)
+	err := n.Encode(newVal.Interface())
+	if err != nil {
+		return nil, err
+	}
+	spliceRemain(n)
+	if o.emitTags {
+		addTags(n, inV.Type())
+	}
+	return n, nil
+}
+
+// marshalPlain marshal in into YAML bytes, without redaction and encryption
+func marshalPlain(in any) ([]byte, error) {
+	n, err := marshalNode(in, newOptions(nil))
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(n)
+}
+
+// marshalRedacted marshal in into YAML bytes without encryption, secret fields are redacted unless o.unredacted
+func marshalRedacted(in any, o *options) ([]byte, error) {
+	n, err := marshalNode(in, o)
+	if err != nil {
+		return nil, err
+	}
+	if !o.unredacted && in != nil {
+		redact(n, reflect.TypeOf(in))
+	}
+	return yaml.Marshal(n)
+}
+
+// MarshalExt marshal in into YAML bytes, values of fields with `extyaml:"secret"` are replaced with SecretMask
+// unless Unredacted option is specified; values of fields with `extyaml:"encrypted"` are encrypted via the KeyProvider
+// of RegisteredTypes; see Canonical option for deterministic output
+func MarshalExt(in any, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	n, err := marshalNode(in, o)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		if o.canonical {
+			canonicalize(n, reflect.TypeOf(in))
+		}
+		err = RegisteredTypes.encryptValues(n, reflect.TypeOf(in))
+		if err != nil {
+			return nil, err
+		}
+		if !o.unredacted {
+			redact(n, reflect.TypeOf(in))
+		}
+	}
+	return yaml.Marshal(n)
 }
@@ -0,0 +1,229 @@
+package extyaml
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ExtTag is the struct field tag key for extyaml options, options are separated by comma,
+// each option is either a flag or a key=value pair, e.g. `extyaml:"merge=append"`;
+// a value containing comma could be quoted with single quote, e.g. `extyaml:"deprecated='use b, not a'"`
+const ExtTag = "extyaml"
+
+// extTagOpts is the parsed options of ExtTag
+type extTagOpts map[string]string
+
+// parseExtTag parses the options of ExtTag in tag
+func parseExtTag(tag reflect.StructTag) extTagOpts {
+	r := extTagOpts{}
+	s := tag.Get(ExtTag)
+	for s != "" {
+		//the end of the option, a comma not in quotes
+		i, quoted := 0, false
+		for ; i < len(s) && (s[i] != ',' || quoted); i++ {
+			if s[i] == '\'' {
+				quoted = !quoted
+			}
+		}
+		item := strings.TrimSpace(s[:i])
+		s = strings.TrimPrefix(s[i:], ",")
+		if item == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(item, "=")
+		v = strings.TrimSpace(v)
+		if len(v) >= 2 && v[0] == '\'' && v[len(v)-1] == '\'' {
+			v = v[1 : len(v)-1]
+		}
+		r[strings.TrimSpace(k)] = v
+	}
+	return r
+}
+
+func (o extTagOpts) has(k string) bool {
+	_, exists := o[k]
+	return exists
+}
+
+// yamlField is a struct field that maps to a YAML key
+type yamlField struct {
+	key string
+	//index is the index sequence for reflect.Value.FieldByIndex, more than one if the field is inlined
+	index []int
+	field reflect.StructField
+}
+
+// yamlFields returns the fields of struct type t that are marshaled as YAML keys,
+// non-exported fields, fields with SkipTag and remain field are not included
+func yamlFields(t reflect.Type) []yamlField {
+	var list []yamlField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !isUnexportedEmbedded(field) {
+			continue
+		}
+		if _, exists := field.Tag.Lookup(SkipTag); exists || isRemainField(field) {
+			continue
+		}
+		name, flags := RegisteredTypes.yamlTag(field)
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(flags, "inline") {
+			ft := field.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				for _, f := range yamlFields(ft) {
+					f.index = append([]int{i}, f.index...)
+					list = append(list, f)
+				}
+			}
+			continue
+		}
+		list = append(list, yamlField{key: name, index: []int{i}, field: field})
+	}
+	return list
+}
+
+// yamlFieldByKey returns the field of struct type t that maps to YAML key
+func yamlFieldByKey(t reflect.Type, key string) (yamlField, bool) {
+	for _, f := range yamlFields(t) {
+		if f.key == key {
+			return f, true
+		}
+	}
+	return yamlField{}, false
+}
+
+// indirectType returns the type t points to if t is pointer
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isCodecType returns true if t is marshaled as a whole by a registered codec or marshaling method
+func isCodecType(t reflect.Type) bool {
+	if RegisteredTypes.isSupportedType(t, true) || t == yamlNodeType {
+		return true
+	}
+	return t.Implements(textMarshalerInt) || t.Implements(yamlMarshalerInt) ||
+		reflect.PointerTo(t).Implements(textUnmarshalerInt) || reflect.PointerTo(t).Implements(yamlUnmarshalerInt)
+}
+
+// fieldValues calls fn for the value node of every field with ExtTag option opt in n, t is the Go type n maps to;
+// the fields under a matched field are not visited.
+func fieldValues(n *yaml.Node, t reflect.Type, opt string, fn func(v *yaml.Node) error) error {
+	if n.Kind == yaml.DocumentNode {
+		for _, c := range n.Content {
+			if err := fieldValues(c, t, opt, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	t = indirectType(t)
+	if isCodecType(t) {
+		return nil
+	}
+	var children []*yaml.Node
+	var types []reflect.Type
+	switch {
+	case n.Kind == yaml.MappingNode && t.Kind() == reflect.Struct:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			f, ok := yamlFieldByKey(t, n.Content[i].Value)
+			if !ok {
+				continue
+			}
+			if parseExtTag(f.field.Tag).has(opt) {
+				if err := fn(n.Content[i+1]); err != nil {
+					return err
+				}
+				continue
+			}
+			children = append(children, n.Content[i+1])
+			types = append(types, f.field.Type)
+		}
+	case n.Kind == yaml.MappingNode && t.Kind() == reflect.Map:
+		for i := 1; i < len(n.Content); i += 2 {
+			children = append(children, n.Content[i])
+			types = append(types, t.Elem())
+		}
+	case n.Kind == yaml.SequenceNode && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+		for _, c := range n.Content {
+			children = append(children, c)
+			types = append(types, t.Elem())
+		}
+	}
+	for i, c := range children {
+		if err := fieldValues(c, types[i], opt, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// walkNode calls fn for n and all its descendants, t is the Go type n maps to, nil if unknown;
+// fn is called before visiting the children of n, so it could modify n.
+// the children of a node that maps to a type marshaled by codec are not visited.
+func walkNode(n *yaml.Node, t reflect.Type, fn func(n *yaml.Node, t reflect.Type) error) error {
+	if t != nil {
+		t = indirectType(t)
+		if t.Kind() == reflect.Interface {
+			t = nil
+		}
+	}
+	if n.Kind == yaml.DocumentNode {
+		for _, c := range n.Content {
+			if err := walkNode(c, t, fn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := fn(n, t)
+	if err != nil {
+		return err
+	}
+	if t != nil && isCodecType(t) {
+		return nil
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			var kt, vt reflect.Type
+			if t != nil {
+				switch t.Kind() {
+				case reflect.Struct:
+					if f, ok := yamlFieldByKey(t, n.Content[i].Value); ok {
+						vt = f.field.Type
+					}
+				case reflect.Map:
+					kt, vt = t.Key(), t.Elem()
+				}
+			}
+			if err := walkNode(n.Content[i], kt, fn); err != nil {
+				return err
+			}
+			if err := walkNode(n.Content[i+1], vt, fn); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		var et reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			et = t.Elem()
+		}
+		for _, c := range n.Content {
+			if err := walkNode(c, et, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package extyaml
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// pathFlag is a flag.Value that sets the value in obj at path via SetPath
+type pathFlag struct {
+	obj  any
+	path string
+	t    reflect.Type
+	//n is the number of times the flag is set, the first time replaces the existing slice or map
+	n int
+	//o is the options of BindFlags, used to render the default value
+	o *options
+}
+
+func (f *pathFlag) String() string {
+	if f == nil || f.obj == nil {
+		return ""
+	}
+	v, secret, err := lookupPath(f.obj, f.path)
+	if err != nil {
+		return ""
+	}
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+	case reflect.Slice, reflect.Map:
+		if v.Len() == 0 {
+			return ""
+		}
+	}
+	r, err := renderValue(v, secret, f.o)
+	if err != nil {
+		return ""
+	}
+	return r
+}
+
+func (f *pathFlag) Set(s string) error {
+	defer func() { f.n++ }()
+	switch f.t.Kind() {
+	case reflect.Slice:
+		if f.n == 0 {
+			err := SetPath(f.obj, f.path, "[]")
+			if err != nil {
+				return err
+			}
+		}
+		return SetPath(f.obj, appendIndex(f.path, f.n), s)
+	case reflect.Map:
+		k, val, ok := strings.Cut(s, "=")
+		if !ok {
+			return fmt.Errorf("%v is not in format of key=value", s)
+		}
+		if f.n == 0 {
+			err := SetPath(f.obj, f.path, "{}")
+			if err != nil {
+				return err
+			}
+		}
+		return SetPath(f.obj, appendKey(f.path, k), val)
+	}
+	return SetPath(f.obj, f.path, s)
+}
+
+// IsBoolFlag makes a bool field usable as "-name" without value
+func (f *pathFlag) IsBoolFlag() bool {
+	return f.t.Kind() == reflect.Bool && !isCodecType(f.t)
+}
+
+// isLeafType returns true if t is a pointer to or a type that is parsed from a single string
+func isLeafType(t reflect.Type) bool {
+	t = indirectType(t)
+	if isCodecType(t) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// BindFlags defines a flag in fs for each leaf field of the struct cfg points to, the flag name is prefix and the YAML path
+// of the field joined by "-", e.g. "server-subnet" for path "server.subnet" with empty prefix;
+// the flag value is parsed via the field's registered codec or TextUnmarshaler and set into cfg when fs.Parse is called,
+// the current value of the field is the default value in help output, a secret field has SecretMask as default value
+// unless Unredacted option is specified.
+// a slice of leaf type is a repeated flag, each flag appends an element; a map with leaf value type is a repeated flag in
+// format of "key=value"; the first flag of a slice or map replaces its existing value.
+// call fs.Parse after UnmarshalExt so that flags override values from the file.
+// fields of a recursive type are not bound. BindFlags panics if cfg is not a non-nil pointer to struct, or a flag name is defined twice.
+func BindFlags(fs *flag.FlagSet, prefix string, cfg any, opts ...Option) {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Sprintf("extyaml: BindFlags requires a non-nil pointer to struct, got %T", cfg))
+	}
+	bindFlags(fs, prefix, cfg, v.Elem().Type(), "", map[reflect.Type]bool{}, newOptions(opts))
+}
+
+func bindFlags(fs *flag.FlagSet, prefix string, cfg any, t reflect.Type, p string, visiting map[reflect.Type]bool, o *options) {
+	if visiting[t] {
+		return
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+	for _, f := range yamlFields(t) {
+		ft := f.field.Type
+		fp := appendKey(p, f.key)
+		name := f.key
+		if prefix != "" {
+			name = prefix + "-" + f.key
+		}
+		switch {
+		case isLeafType(ft):
+			fs.Var(&pathFlag{obj: cfg, path: fp, t: ft, o: o}, name, fp)
+		case ft.Kind() == reflect.Slice && isLeafType(ft.Elem()):
+			fs.Var(&pathFlag{obj: cfg, path: fp, t: ft, o: o}, name, fp+", repeatable")
+		case ft.Kind() == reflect.Map && isLeafType(ft.Key()) && isLeafType(ft.Elem()):
+			fs.Var(&pathFlag{obj: cfg, path: fp, t: ft, o: o}, name, fp+" in format of key=value, repeatable")
+		case indirectType(ft).Kind() == reflect.Struct:
+			bindFlags(fs, name, cfg, indirectType(ft), fp, visiting, o)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package extyaml_test
+
+import (
+	"bytes"
+	"flag"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type flagServer struct {
+	Subnet  net.IPNet
+	Timeout time.Duration `yaml:"time_out"`
+	Enabled bool
+}
+
+type flagConfig struct {
+	Name    string
+	Server  flagServer
+	Backup  *flagServer
+	Macs    []net.HardwareAddr
+	Labels  map[string]int
+	Servers []flagServer
+	Hidden  string `skipyamlmarshal:""`
+	Next    *flagConfig
+}
+
+func TestBindFlags(t *testing.T) {
+	cfg := new(flagConfig)
+	err := extyaml.UnmarshalExt([]byte(`
+name: file
+server:
+  subnet: 10.0.0.0/24
+  time_out: 3s
+macs: [11:22:33:44:55:66]
+labels: {a: 1}
+`), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	extyaml.BindFlags(fs, "", cfg)
+	for _, name := range []string{"name", "server-subnet", "server-time_out", "server-enabled", "backup-subnet", "macs", "labels"} {
+		if fs.Lookup(name) == nil {
+			t.Fatalf("flag %v not defined", name)
+		}
+	}
+	for _, name := range []string{"hidden", "servers", "servers-subnet", "next-name"} {
+		if fs.Lookup(name) != nil {
+			t.Fatalf("flag %v should not be defined", name)
+		}
+	}
+	if d := fs.Lookup("server-subnet").DefValue; d != "10.0.0.0/24" {
+		t.Fatalf("unexpected default %v", d)
+	}
+	buf := new(bytes.Buffer)
+	fs.SetOutput(buf)
+	fs.PrintDefaults()
+	if !strings.Contains(buf.String(), `(default 3s)`) || strings.Contains(buf.String(), "backup-subnet string") {
+		t.Fatalf("unexpected help output\n%v", buf)
+	}
+
+	err = fs.Parse([]string{"-server-subnet", "10.0.1.0/24", "-server-enabled", "-backup-time_out", "1m",
+		"-macs", "aa:bb:cc:dd:ee:01", "-macs", "00:11:22:33:44:55", "-labels", "b=2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "file" || cfg.Server.Subnet.String() != "10.0.1.0/24" || cfg.Server.Timeout != 3*time.Second || !cfg.Server.Enabled {
+		t.Fatalf("unexpected server %+v", cfg)
+	}
+	if cfg.Backup == nil || cfg.Backup.Timeout != time.Minute || cfg.Next != nil {
+		t.Fatalf("unexpected backup %+v", cfg.Backup)
+	}
+	if len(cfg.Macs) != 2 || cfg.Macs[1].String() != "00:11:22:33:44:55" {
+		t.Fatalf("unexpected macs %v", cfg.Macs)
+	}
+	if len(cfg.Labels) != 1 || cfg.Labels["b"] != 2 {
+		t.Fatalf("unexpected labels %v", cfg.Labels)
+	}
+
+	fs.SetOutput(new(bytes.Buffer))
+	err = fs.Parse([]string{"-server-subnet", "10.0.1.300/24"})
+	if err == nil || !strings.Contains(err.Error(), "server.subnet") {
+		t.Fatalf("expect parsing error, got %v", err)
+	}
+	err = fs.Parse([]string{"-labels", "c"})
+	if err == nil || !strings.Contains(err.Error(), "key=value") {
+		t.Fatalf("expect format error, got %v", err)
+	}
+}
+
+func TestBindFlagsSecret(t *testing.T) {
+	cfg := &secretConfig{Name: "n", Auth: secretAuth{User: "u", Password: "p1"}}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	extyaml.BindFlags(fs, "", cfg)
+	if d := fs.Lookup("auth-password").DefValue; d != extyaml.SecretMask {
+		t.Fatalf("unexpected default %v", d)
+	}
+	if d := fs.Lookup("name").DefValue; d != "n" {
+		t.Fatalf("unexpected default %v", d)
+	}
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	extyaml.BindFlags(fs, "", cfg, extyaml.Unredacted())
+	if d := fs.Lookup("auth-password").DefValue; d != "p1" {
+		t.Fatalf("unexpected default %v", d)
+	}
+}
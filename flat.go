@@ -0,0 +1,125 @@
+package extyaml
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// flatten adds the leaves of n into r, p is the flat key of n
+func flatten(n *yaml.Node, p string, r map[string]string) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			flatten(c, p, r)
+		}
+	case yaml.MappingNode:
+		if len(n.Content) == 0 {
+			r[p] = "{}"
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			flatten(n.Content[i+1], appendKey(p, n.Content[i].Value), r)
+		}
+	case yaml.SequenceNode:
+		if len(n.Content) == 0 {
+			r[p] = "[]"
+		}
+		for i, c := range n.Content {
+			flatten(c, appendKey(p, strconv.Itoa(i)), r)
+		}
+	case yaml.AliasNode:
+		flatten(n.Alias, p, r)
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null":
+		case EncTag:
+			r[p] = EncTag + " " + n.Value
+		default:
+			r[p] = n.Value
+		}
+	}
+}
+
+// MarshalFlat marshals in into flat key/value pairs, e.g. "servers.0.subnet": "10.0.0.0/24";
+// a key is the YAML path of a leaf value with indexes in the same form as keys, a key containing any of `.[]"` is quoted
+// in brackets, e.g. `labels["a.b"]`; leaf values are rendered via registered codecs the same way as MarshalExt,
+// null values are omitted, an empty slice or map is "[]" or "{}".
+// secret fields are redacted unless Unredacted option is specified; encrypted fields are encrypted via the KeyProvider
+// of RegisteredTypes, their values are "!enc <base64 ciphertext>", which are decrypted by UnmarshalFlat.
+func MarshalFlat(in any, opts ...Option) (map[string]string, error) {
+	o := newOptions(opts)
+	n, err := marshalNode(in, o)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		err = RegisteredTypes.encryptValues(n, reflect.TypeOf(in))
+		if err != nil {
+			return nil, err
+		}
+		if !o.unredacted {
+			redact(n, reflect.TypeOf(in))
+		}
+	}
+	r := make(map[string]string)
+	flatten(n, "", r)
+	return r, nil
+}
+
+// UnmarshalFlat sets the fields of the value out points to from flat key/value pairs in the format of MarshalFlat,
+// values are parsed via registered codecs the same way as SetPath; a slice with any key is replaced,
+// its elements must be set from index 0 without gaps; a value "!enc <base64 ciphertext>" of an encrypted field is decrypted first.
+func UnmarshalFlat(in map[string]string, out any) error {
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("the object to unmarshal is not a pointer")
+	}
+	list := make([]pathValue, 0, len(in))
+	for k, val := range in {
+		if strings.HasPrefix(val, EncTag+" ") && inEncryptedField(v.Type(), k) {
+			plain, err := RegisteredTypes.decryptNode(&yaml.Node{Kind: yaml.ScalarNode, Tag: EncTag, Value: strings.TrimPrefix(val, EncTag+" ")})
+			if err != nil {
+				return fmt.Errorf("%v: failed to resolve %v: %w", k, EncTag, err)
+			}
+			val = plain.Value
+		}
+		list = append(list, pathValue{path: k, value: val})
+	}
+	//keys are sorted first so that the result doesn't depend on map iteration order
+	sort.Slice(list, func(i, j int) bool { return list[i].path < list[j].path })
+	return setPaths(out, list)
+}
+
+// inEncryptedField returns true if path p of Go type t is in a field with EncryptedOpt
+func inEncryptedField(t reflect.Type, p string) bool {
+	keys, err := parsePath(p)
+	if err != nil {
+		return false
+	}
+	for _, k := range keys {
+		t = indirectType(t)
+		switch t.Kind() {
+		case reflect.Struct:
+			if isCodecType(t) {
+				return false
+			}
+			f, ok := yamlFieldByKey(t, k)
+			if !ok {
+				return false
+			}
+			if parseExtTag(f.field.Tag).has(EncryptedOpt) {
+				return true
+			}
+			t = f.field.Type
+		case reflect.Map, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		default:
+			return false
+		}
+	}
+	return false
+}
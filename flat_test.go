@@ -0,0 +1,138 @@
+package extyaml_test
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type flatServer struct {
+	Name   string
+	Subnet net.IPNet
+}
+
+type flatConfig struct {
+	Name    string
+	Servers []flatServer
+	Labels  map[string]string
+	Ports   map[int]*flatServer
+	Times   []time.Time
+	Empty   []int
+	Nil     *flatServer
+	Hidden  string `skipyamlmarshal:""`
+}
+
+func TestMarshalFlat(t *testing.T) {
+	extyaml.RegisterExt[time.Time](timeToStr, timeFromStr)
+	ts, _ := time.Parse(time.RFC3339, "2023-01-02T03:04:05Z")
+	cfg := &flatConfig{
+		Name: "null",
+		Servers: []flatServer{
+			{Name: "s1", Subnet: mustCIDR("10.0.0.0/24")},
+			{Name: "s2", Subnet: mustCIDR("10.0.1.0/24")},
+		},
+		Labels: map[string]string{"a.b": "x", "c": "1"},
+		Ports:  map[int]*flatServer{80: {Name: "web"}},
+		Times:  []time.Time{ts},
+		Empty:  []int{},
+		Hidden: "hidden",
+	}
+	flat, err := extyaml.MarshalFlat(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := map[string]string{
+		"name":             "null",
+		"servers.0.name":   "s1",
+		"servers.0.subnet": "10.0.0.0/24",
+		"servers.1.name":   "s2",
+		"servers.1.subnet": "10.0.1.0/24",
+		`labels["a.b"]`:    "x",
+		"labels.c":         "1",
+		"ports.80.name":    "web",
+		"ports.80.subnet":  "",
+		"times.0":          ts.Format(time.RFC1123),
+		"empty":            "[]",
+	}
+	if !reflect.DeepEqual(flat, expect) {
+		t.Fatalf("unexpected result %v", flat)
+	}
+
+	out := &flatConfig{Servers: []flatServer{{}, {}, {}}}
+	err = extyaml.UnmarshalFlat(flat, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Hidden != "" || out.Nil != nil || out.Ports[80].Name != "web" || len(out.Servers) != 2 {
+		t.Fatalf("unexpected result %+v", out)
+	}
+	again, err := extyaml.MarshalFlat(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, expect) {
+		t.Fatalf("unexpected result %v", again)
+	}
+
+	for _, c := range []struct {
+		key, value, err string
+	}{
+		{"servers.0.subnet", "10.0.0.300/24", "servers[0].subnet"},
+		{"servers.1.name", "x", "invalid index 1"},
+		{"unknown", "x", "unknown key unknown"},
+	} {
+		err := extyaml.UnmarshalFlat(map[string]string{c.key: c.value}, new(flatConfig))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("%v: expect error %q, got %v", c.key, c.err, err)
+		}
+	}
+}
+
+func TestMarshalFlatSecret(t *testing.T) {
+	kv, err := extyaml.MarshalFlat(&secretConfig{Name: "n", Auth: secretAuth{User: "u", Password: "p1"}, Tokens: []string{"t1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kv["name"] != "n" || kv["auth.password"] != extyaml.SecretMask || kv["tokens.0"] != extyaml.SecretMask {
+		t.Fatalf("unexpected result %v", kv)
+	}
+	kv, err = extyaml.MarshalFlat(&secretConfig{Auth: secretAuth{Password: "p1"}}, extyaml.Unredacted())
+	if err != nil || kv["auth.password"] != "p1" {
+		t.Fatalf("unexpected result %v, %v", kv, err)
+	}
+
+	cfg := &encryptConfig{User: "u", Password: "p1", Port: 8080, Tokens: []string{"t1"}}
+	_, err = extyaml.MarshalFlat(cfg)
+	if err == nil || !strings.Contains(err.Error(), "no key provider") {
+		t.Fatalf("expect no key provider error, got %v", err)
+	}
+	p, err := extyaml.NewAESGCMKeyProvider([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	extyaml.RegisteredTypes.SetKeyProvider(p)
+	defer extyaml.RegisteredTypes.SetKeyProvider(nil)
+	kv, err = extyaml.MarshalFlat(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range []string{"password", "port", "tokens.0"} {
+		if !strings.HasPrefix(kv[k], "!enc ") {
+			t.Fatalf("%v is not encrypted: %v", k, kv)
+		}
+	}
+	out := new(encryptConfig)
+	err = extyaml.UnmarshalFlat(kv, out)
+	if err != nil || out.User != "u" || out.Password != "p1" || out.Port != 8080 || len(out.Tokens) != 1 || out.Tokens[0] != "t1" {
+		t.Fatalf("unexpected result %+v, %v", out, err)
+	}
+	//"!enc" prefix of a field that is not encrypted is kept as it is
+	err = extyaml.UnmarshalFlat(map[string]string{"user": "!enc x"}, out)
+	if err != nil || out.User != "!enc x" {
+		t.Fatalf("unexpected result %+v, %v", out, err)
+	}
+}
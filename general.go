@@ -1,6 +1,7 @@
 package extyaml
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
 
@@ -27,6 +28,10 @@ func (ext *generalExt[T]) setVal(in any) {
 }
 
 func (ext generalExt[T]) toOrig() any {
+	if ext.origV == nil {
+		//a zero value, e.g. created by yaml decoder for an absent key
+		return *new(T)
+	}
 	return *ext.origV
 }
 
@@ -49,7 +54,46 @@ func (ext *generalExt[T]) UnmarshalYAML(value *yaml.Node) error {
 	if fromFunc == nil {
 		return fmt.Errorf("can't find %v fromFunc, it is not registed?", pkgName)
 	}
+	err := RegisteredTypes.checkTag(value, RegisteredTypes.Get(pkgName))
+	if err != nil {
+		return err
+	}
 	val, err := fromFunc(value.Value)
+	if err != nil {
+		return newPosError(value, err)
+	}
+	ext.origV = new(T)
+	*ext.origV = val.(T)
+	return nil
+}
+
+// MarshalJSON renders the value as a JSON string via its registered ToStr
+func (ext generalExt[T]) MarshalJSON() ([]byte, error) {
+	pkgName := GetTypeName(reflect.TypeOf(new(T)).Elem())
+	toFunc := RegisteredTypes.Get(pkgName).toStr
+	if toFunc == nil {
+		return nil, fmt.Errorf("can't find %v toStr Func, it is not registed?", pkgName)
+	}
+	s, err := toFunc(ext.toOrig())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(s)
+}
+
+// UnmarshalJSON parses a JSON string via the registered FromStr
+func (ext *generalExt[T]) UnmarshalJSON(buf []byte) error {
+	pkgName := GetTypeName(reflect.TypeOf(new(T)).Elem())
+	fromFunc := RegisteredTypes.Get(pkgName).fromStr
+	if fromFunc == nil {
+		return fmt.Errorf("can't find %v fromFunc, it is not registed?", pkgName)
+	}
+	var s string
+	err := json.Unmarshal(buf, &s)
+	if err != nil {
+		return err
+	}
+	val, err := fromFunc(s)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,243 @@
+package extyaml
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	//IncludeTag is the YAML tag to include another file, e.g. "!include sub.yaml"
+	IncludeTag = "!include"
+	//IncludeGlobTag is the YAML tag to include all files matching the pattern, e.g. "!include-glob routes/*.yaml"
+	IncludeGlobTag = "!include-glob"
+	//DefaultMaxIncludeDepth is the default max nesting depth of included files
+	DefaultMaxIncludeDepth = 16
+)
+
+// WithMaxIncludeDepth sets the max nesting depth of included files, default is DefaultMaxIncludeDepth
+func WithMaxIncludeDepth(depth int) Option {
+	return func(o *options) {
+		o.maxIncludeDepth = depth
+	}
+}
+
+// includer resolves include tags of files in fsys
+type includer struct {
+	fsys     fs.FS
+	maxDepth int
+	//stack is the list of files currently being included, used for cycle detection
+	stack []string
+	//sources records the file name of each resolved node
+	sources map[*yaml.Node]string
+}
+
+func newIncluder(fsys fs.FS, maxDepth int) *includer {
+	return &includer{
+		fsys:     fsys,
+		maxDepth: maxDepth,
+		sources:  make(map[*yaml.Node]string),
+	}
+}
+
+// load reads file name, parses it and resolves all its include tags
+func (inc *includer) load(name string) (*yaml.Node, error) {
+	for i, s := range inc.stack {
+		if s == name {
+			return nil, fmt.Errorf("include cycle: %v", strings.Join(append(inc.stack[i:], name), " -> "))
+		}
+	}
+	if len(inc.stack) > inc.maxDepth {
+		return nil, fmt.Errorf("%v: exceeds max include depth %d", name, inc.maxDepth)
+	}
+	buf, err := fs.ReadFile(inc.fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	doc := new(yaml.Node)
+	err = yaml.Unmarshal(buf, doc)
+	if err != nil {
+		perr := &PosError{Source: name, Err: err}
+		if m := yamlErrLine.FindStringSubmatch(err.Error()); m != nil {
+			perr.Line, _ = strconv.Atoi(m[1])
+		}
+		return nil, perr
+	}
+	inc.stack = append(inc.stack, name)
+	defer func() {
+		inc.stack = inc.stack[:len(inc.stack)-1]
+	}()
+	err = inc.resolve(doc, name)
+	if err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// loadContent returns the content node of file name, a null node if the file is empty
+func (inc *includer) loadContent(name string) (*yaml.Node, error) {
+	doc, err := inc.load(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}, nil
+	}
+	return doc.Content[0], nil
+}
+
+// target returns the path of included file p relative to the including file name
+func (inc *includer) target(name, p string) (string, error) {
+	var r string
+	if strings.HasPrefix(p, "/") {
+		//relative to the root of fsys
+		r = path.Clean(strings.TrimLeft(p, "/"))
+	} else {
+		r = path.Join(path.Dir(name), p)
+	}
+	if !fs.ValidPath(r) {
+		return "", fmt.Errorf("%v is not a valid path", p)
+	}
+	return r, nil
+}
+
+// resolve replaces every include tagged node under n, name is the file contains n
+func (inc *includer) resolve(n *yaml.Node, name string) error {
+	inc.sources[n] = name
+	switch n.Tag {
+	case IncludeTag, IncludeGlobTag:
+		if n.Kind != yaml.ScalarNode {
+			return &PosError{Source: name, Line: n.Line, Column: n.Column, Err: fmt.Errorf("%v requires a scalar path", n.Tag)}
+		}
+		var included *yaml.Node
+		var err error
+		if n.Tag == IncludeTag {
+			included, err = inc.includeFile(name, n.Value)
+		} else {
+			included, err = inc.includeGlob(name, n.Value)
+		}
+		if err != nil {
+			var perr *PosError
+			if errors.As(err, &perr) {
+				return err
+			}
+			return &PosError{Source: name, Line: n.Line, Column: n.Column, Err: err}
+		}
+		src := inc.sources[included]
+		*n = *included
+		inc.sources[n] = src
+		return nil
+	}
+	for _, c := range n.Content {
+		err := inc.resolve(c, name)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (inc *includer) includeFile(name, p string) (*yaml.Node, error) {
+	t, err := inc.target(name, p)
+	if err != nil {
+		return nil, err
+	}
+	return inc.loadContent(t)
+}
+
+// includeGlob includes all files matching pattern p, in lexical order;
+// sequences are concatenated, mappings are merged, a scalar becomes a sequence item
+func (inc *includer) includeGlob(name, p string) (*yaml.Node, error) {
+	pattern, err := inc.target(name, p)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := fs.Glob(inc.fsys, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}, nil
+	}
+	var r *yaml.Node
+	//keySrc records which file defines the key when merging mappings
+	keySrc := make(map[string]string)
+	for _, m := range matches {
+		frag, err := inc.loadContent(m)
+		if err != nil {
+			return nil, err
+		}
+		if frag.ShortTag() == "!!null" {
+			continue
+		}
+		for _, c := range frag.Content {
+			//the items are moved into r, keep their source file
+			if _, exists := inc.sources[c]; !exists {
+				inc.sources[c] = m
+			}
+		}
+		if r == nil {
+			if frag.Kind == yaml.MappingNode {
+				r = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			} else {
+				r = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+			}
+			inc.sources[r] = m
+		}
+		switch {
+		case r.Kind == yaml.MappingNode && frag.Kind == yaml.MappingNode:
+			for i := 0; i+1 < len(frag.Content); i += 2 {
+				k := frag.Content[i].Value
+				if src, exists := keySrc[k]; exists {
+					return nil, &PosError{Source: m, Line: frag.Content[i].Line, Column: frag.Content[i].Column,
+						Err: fmt.Errorf("key %v already defined in %v", k, src)}
+				}
+				keySrc[k] = m
+			}
+			r.Content = append(r.Content, frag.Content...)
+		case r.Kind == yaml.SequenceNode && frag.Kind == yaml.SequenceNode:
+			r.Content = append(r.Content, frag.Content...)
+		case r.Kind == yaml.SequenceNode && frag.Kind == yaml.ScalarNode:
+			r.Content = append(r.Content, frag)
+		default:
+			return nil, fmt.Errorf("%v: can't merge fragment into %v of %v", m, r.ShortTag(), p)
+		}
+	}
+	if r == nil {
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null"}, nil
+	}
+	return r, nil
+}
+
+// yamlErrLine matches the line number in errors of yaml.v3, e.g. "yaml: line 2: did not find expected key"
+var yamlErrLine = regexp.MustCompile(`line (\d+): `)
+
+// UnmarshalExtFS unmarshal YAML file name in fsys into out, out must be a pointer;
+// "!include <path>" tag is replaced with the content of the file at path,
+// "!include-glob <pattern>" tag is replaced with the content of all files that matches the pattern,
+// sequences of these files are concatenated, mappings are merged;
+// path and pattern are relative to the including file, or relative to root of fsys if it starts with "/".
+func UnmarshalExtFS(fsys fs.FS, name string, out any, opts ...Option) error {
+	o := newOptions(opts)
+	inc := newIncluder(fsys, o.maxIncludeDepth)
+	doc, err := inc.load(path.Clean(name))
+	if err != nil {
+		return err
+	}
+	o.sources = inc.sources
+	err = unmarshalNode(doc, out, o)
+	if err != nil {
+		var perr *PosError
+		if errors.As(err, &perr) && perr.Source == "" {
+			perr.Source = inc.sources[perr.node]
+		}
+		return err
+	}
+	return postUnmarshal(out)
+}
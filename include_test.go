@@ -0,0 +1,159 @@
+package extyaml_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type includeRoute struct {
+	Name   string
+	Subnet net.IPNet
+}
+
+type includeConfig struct {
+	Name   string
+	Mac    net.HardwareAddr
+	Routes []includeRoute
+	Peers  map[string]int
+}
+
+func TestUnmarshalExtFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.yaml": {Data: []byte(`name: router1
+mac: !include iface/mac.yaml
+routes: !include-glob routes/*.yaml
+peers: !include-glob /peers/*.yaml
+`)},
+		"iface/mac.yaml":     {Data: []byte(`11:22:33:44:55:66`)},
+		"routes/a.yaml":      {Data: []byte("- name: a\n  subnet: 10.0.0.0/8\n")},
+		"routes/b.yaml":      {Data: []byte("- name: b\n  subnet: 192.168.1.0/24\n")},
+		"peers/p1.yaml":      {Data: []byte("p1: 1\n")},
+		"peers/p2.yaml":      {Data: []byte("p2: 2\n")},
+		"cycle.yaml":         {Data: []byte("name: !include cycle2.yaml\n")},
+		"cycle2.yaml":        {Data: []byte("!include cycle.yaml\n")},
+		"badmac.yaml":        {Data: []byte("name: x\nmac: !include sub/badmac.yaml\n")},
+		"sub/badmac.yaml":    {Data: []byte("11:22:33:zz:55:66\n")},
+		"dup.yaml":           {Data: []byte("peers: !include-glob dup/*.yaml\n")},
+		"dup/a.yaml":         {Data: []byte("p1: 1\n")},
+		"dup/b.yaml":         {Data: []byte("p1: 2\n")},
+		"escape.yaml":        {Data: []byte("name: !include ../secret.yaml\n")},
+		"deep.yaml":          {Data: []byte("name: !include deep2.yaml\n")},
+		"deep2.yaml":         {Data: []byte("!include deep3.yaml\n")},
+		"deep3.yaml":         {Data: []byte("deep\n")},
+		"routes/readme.text": {Data: []byte("not included")},
+	}
+	cfg := new(includeConfig)
+	err := extyaml.UnmarshalExtFS(fsys, "main.yaml", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "router1" || cfg.Mac.String() != "11:22:33:44:55:66" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	if len(cfg.Routes) != 2 || cfg.Routes[0].Name != "a" || cfg.Routes[1].Subnet.String() != "192.168.1.0/24" {
+		t.Fatalf("unexpected routes %+v", cfg.Routes)
+	}
+	if len(cfg.Peers) != 2 || cfg.Peers["p1"] != 1 || cfg.Peers["p2"] != 2 {
+		t.Fatalf("unexpected peers %+v", cfg.Peers)
+	}
+
+	err = extyaml.UnmarshalExtFS(fsys, "cycle.yaml", new(includeConfig))
+	if err == nil || !strings.Contains(err.Error(), "include cycle: cycle.yaml -> cycle2.yaml -> cycle.yaml") {
+		t.Fatalf("expect cycle error, got %v", err)
+	}
+
+	err = extyaml.UnmarshalExtFS(fsys, "badmac.yaml", new(includeConfig))
+	var perr *extyaml.PosError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expect a PosError, got %v", err)
+	}
+	if perr.Source != "sub/badmac.yaml" || perr.Line != 1 {
+		t.Fatalf("unexpected error position %v", perr)
+	}
+
+	err = extyaml.UnmarshalExtFS(fsys, "dup.yaml", new(includeConfig))
+	if err == nil || !strings.Contains(err.Error(), "already defined in dup/a.yaml") {
+		t.Fatalf("expect duplicate key error, got %v", err)
+	}
+
+	err = extyaml.UnmarshalExtFS(fsys, "escape.yaml", new(includeConfig))
+	if err == nil || !strings.Contains(err.Error(), "escape.yaml:1:7") {
+		t.Fatalf("expect invalid path error, got %v", err)
+	}
+
+	err = extyaml.UnmarshalExtFS(fsys, "deep.yaml", new(includeConfig), extyaml.WithMaxIncludeDepth(1))
+	if err == nil || !strings.Contains(err.Error(), "exceeds max include depth") {
+		t.Fatalf("expect max depth error, got %v", err)
+	}
+	cfg = new(includeConfig)
+	err = extyaml.UnmarshalExtFS(fsys, "deep.yaml", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "deep" {
+		t.Fatalf("unexpected name %v", cfg.Name)
+	}
+}
+
+func TestUnmarshalExtFSIncludedError(t *testing.T) {
+	fsys := fstest.MapFS{
+		"main.yaml":       {Data: []byte("name: top\nroutes: !include routes/all.yaml\n")},
+		"routes/all.yaml": {Data: []byte("- !include a.yaml\n")},
+		"routes/a.yaml":   {Data: []byte("name:\n  bad: type\n")},
+		"syntax.yaml":     {Data: []byte("name: !include sub/syntax.yaml\n")},
+		"sub/syntax.yaml": {Data: []byte("a: 1\nb: [\n")},
+		"peers.yaml":      {Data: []byte("peers: !include sub/peers.yaml\n")},
+		"sub/peers.yaml":  {Data: []byte("p1: 1\np2: notint\n")},
+	}
+	var perr *extyaml.PosError
+	err := extyaml.UnmarshalExtFS(fsys, "main.yaml", new(includeConfig))
+	if !errors.As(err, &perr) {
+		t.Fatalf("expect a PosError, got %v", err)
+	}
+	if perr.Source != "routes/a.yaml" || perr.Line != 2 {
+		t.Fatalf("unexpected error position %v", perr)
+	}
+
+	err = extyaml.UnmarshalExtFS(fsys, "peers.yaml", new(includeConfig))
+	if !errors.As(err, &perr) {
+		t.Fatalf("expect a PosError, got %v", err)
+	}
+	if perr.Source != "sub/peers.yaml" || perr.Line != 2 || perr.Column != 5 {
+		t.Fatalf("unexpected error position %v", perr)
+	}
+
+	err = extyaml.UnmarshalExtFS(fsys, "syntax.yaml", new(includeConfig))
+	if !errors.As(err, &perr) {
+		t.Fatalf("expect a PosError, got %v", err)
+	}
+	if perr.Source != "sub/syntax.yaml" {
+		t.Fatalf("unexpected error source %v", perr)
+	}
+}
+
+type includeSameLine struct {
+	A struct{ Port string }
+	B struct{ Port int }
+}
+
+func TestUnmarshalExtFSSameLineError(t *testing.T) {
+	//the same value at the same line of two files, only the second one is a type error
+	fsys := fstest.MapFS{
+		"main.yaml": {Data: []byte("a: !include a.yaml\nb: !include b.yaml\n")},
+		"a.yaml":    {Data: []byte("port: x\n")},
+		"b.yaml":    {Data: []byte("port: x\n")},
+	}
+	err := extyaml.UnmarshalExtFS(fsys, "main.yaml", new(includeSameLine))
+	var perr *extyaml.PosError
+	if !errors.As(err, &perr) {
+		t.Fatalf("expect a PosError, got %v", err)
+	}
+	if perr.Source != "b.yaml" || perr.Line != 1 || perr.Column != 7 {
+		t.Fatalf("unexpected error position %v", perr)
+	}
+}
@@ -0,0 +1,272 @@
+package extyaml
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeJSON writes n as JSON into buf;
+// integers, floats, booleans and nulls are JSON literals, other scalars (including registered types) are strings,
+// a non-finite float is a string of its YAML form, e.g. ".inf"; an encrypted value is a string "!enc <base64 ciphertext>"
+func writeJSON(buf *bytes.Buffer, n *yaml.Node) error {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			buf.WriteString("null")
+			return nil
+		}
+		return writeJSON(buf, n.Content[0])
+	case yaml.AliasNode:
+		return writeJSON(buf, n.Alias)
+	case yaml.MappingNode:
+		buf.WriteByte('{')
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			k, _ := json.Marshal(n.Content[i].Value)
+			buf.Write(k)
+			buf.WriteByte(':')
+			if err := writeJSON(buf, n.Content[i+1]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case yaml.SequenceNode:
+		buf.WriteByte('[')
+		for i, c := range n.Content {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeJSON(buf, c); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case yaml.ScalarNode:
+		switch n.ShortTag() {
+		case "!!null", ResetTag:
+			buf.WriteString("null")
+			return nil
+		case "!!bool":
+			var b bool
+			if err := n.Decode(&b); err == nil {
+				fmt.Fprint(buf, b)
+				return nil
+			}
+		case "!!int", "!!float":
+			//YAML numbers like 0x1f or .inf are not valid JSON
+			if json.Valid([]byte(n.Value)) {
+				buf.WriteString(n.Value)
+				return nil
+			}
+		case EncTag:
+			s, _ := json.Marshal(EncTag + " " + n.Value)
+			buf.Write(s)
+			return nil
+		}
+		s, _ := json.Marshal(n.Value)
+		buf.Write(s)
+	default:
+		return fmt.Errorf("unsupported YAML node kind %v", n.Kind)
+	}
+	return nil
+}
+
+// MarshalJSONExt marshal in into JSON bytes via registered codecs, the same way as MarshalExt:
+// the keys are YAML keys, registered types are strings rendered by their ToStr, fields with SkipTag are omitted,
+// secret fields are redacted unless Unredacted option is specified; encrypted fields are encrypted via the KeyProvider
+// of RegisteredTypes, and written as strings "!enc <base64 ciphertext>" which are decrypted by UnmarshalJSONExt.
+func MarshalJSONExt(in any, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	n, err := marshalNode(in, o)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		err = RegisteredTypes.encryptValues(n, reflect.TypeOf(in))
+		if err != nil {
+			return nil, err
+		}
+		if !o.unredacted {
+			redact(n, reflect.TypeOf(in))
+		}
+	}
+	buf := new(bytes.Buffer)
+	err = writeJSON(buf, n)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalJSONExtDefault marshal in into JSON bytes like MarshalJSONExt, without the fields that are same as def,
+// see MarshalExtDefault; a deleted map key has a null value.
+func MarshalJSONExtDefault(in, def any, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	n, err := marshalDefaultNode(in, def, o)
+	if err != nil {
+		return nil, err
+	}
+	if in != nil {
+		err = RegisteredTypes.encryptValues(n, reflect.TypeOf(in))
+		if err != nil {
+			return nil, err
+		}
+		if !o.unredacted {
+			redact(n, reflect.TypeOf(in))
+		}
+	}
+	buf := new(bytes.Buffer)
+	err = writeJSON(buf, n)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// jsonParser parses JSON into yaml.Node, keeping the order of object keys and positions of values
+type jsonParser struct {
+	buf []byte
+	dec *json.Decoder
+}
+
+// pos returns the line and column of the next token
+func (p *jsonParser) pos() (line, column int) {
+	off := int(p.dec.InputOffset())
+	for off < len(p.buf) && bytes.IndexByte([]byte(" \t\r\n,:"), p.buf[off]) >= 0 {
+		off++
+	}
+	line = 1 + bytes.Count(p.buf[:off], []byte("\n"))
+	column = off - bytes.LastIndexByte(p.buf[:off], '\n')
+	return
+}
+
+func (p *jsonParser) parse() (*yaml.Node, error) {
+	line, column := p.pos()
+	tok, err := p.dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	n := &yaml.Node{Line: line, Column: column}
+	switch v := tok.(type) {
+	case json.Delim:
+		if v == '{' {
+			n.Kind, n.Tag = yaml.MappingNode, "!!map"
+		} else {
+			n.Kind, n.Tag = yaml.SequenceNode, "!!seq"
+		}
+		for p.dec.More() {
+			if n.Kind == yaml.MappingNode {
+				line, column := p.pos()
+				k, err := p.dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				n.Content = append(n.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k.(string), Line: line, Column: column})
+			}
+			c, err := p.parse()
+			if err != nil {
+				return nil, err
+			}
+			n.Content = append(n.Content, c)
+		}
+		//the closing delimiter
+		if _, err := p.dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!str", v
+	case json.Number:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!int", v.String()
+		if strings.ContainsAny(n.Value, ".eE") {
+			n.Tag = "!!float"
+		}
+	case bool:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!bool", fmt.Sprint(v)
+	case nil:
+		n.Kind, n.Tag, n.Value = yaml.ScalarNode, "!!null", "null"
+	}
+	return n, nil
+}
+
+// restoreJSONTags restores the YAML scalars written as JSON strings by writeJSON in n, t is the Go type n maps to:
+// "!enc <base64 ciphertext>" of encrypted fields, and non-finite floats of float fields, e.g. ".inf" or "NaN"
+func restoreJSONTags(n *yaml.Node, t reflect.Type) error {
+	err := fieldValues(n, t, EncryptedOpt, func(v *yaml.Node) error {
+		return walkNode(v, nil, func(c *yaml.Node, _ reflect.Type) error {
+			if c.Kind == yaml.ScalarNode && c.Tag == "!!str" && strings.HasPrefix(c.Value, EncTag+" ") {
+				c.Tag, c.Value = EncTag, strings.TrimPrefix(c.Value, EncTag+" ")
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return walkNode(n, t, func(c *yaml.Node, t reflect.Type) error {
+		if c.Kind != yaml.ScalarNode || c.Tag != "!!str" || t == nil || isCodecType(t) ||
+			(t.Kind() != reflect.Float32 && t.Kind() != reflect.Float64) {
+			return nil
+		}
+		f, err := strconv.ParseFloat(c.Value, 64)
+		if err != nil {
+			//the YAML form, e.g. ".inf"
+			if (&yaml.Node{Kind: yaml.ScalarNode, Value: c.Value}).Decode(&f) != nil {
+				return nil
+			}
+		}
+		switch {
+		case math.IsNaN(f):
+			c.Tag, c.Value = "!!float", ".nan"
+		case math.IsInf(f, 1):
+			c.Tag, c.Value = "!!float", ".inf"
+		case math.IsInf(f, -1):
+			c.Tag, c.Value = "!!float", "-.inf"
+		}
+		return nil
+	})
+}
+
+// UnmarshalJSONExt unmarshal JSON bytes into out via registered codecs, the same way as UnmarshalExt,
+// out must be a pointer; a JSON string is decoded as a YAML string, e.g. "1" can't be decoded into an int field,
+// except a non-finite float like ".inf" or "NaN" of a float field and an encrypted value written by MarshalJSONExt.
+func UnmarshalJSONExt(buf []byte, out any, opts ...Option) error {
+	if !json.Valid(buf) {
+		var v any
+		//get the syntax error from encoding/json
+		return json.Unmarshal(buf, &v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	p := &jsonParser{buf: buf, dec: dec}
+	n, err := p.parse()
+	if err != nil {
+		return err
+	}
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{n}}
+	if out != nil {
+		err = restoreJSONTags(doc, reflect.TypeOf(out))
+		if err != nil {
+			return err
+		}
+	}
+	o := newOptions(opts)
+	err = unmarshalNode(doc, out, o)
+	if err != nil {
+		var perr *PosError
+		if errors.As(err, &perr) && perr.Source == "" {
+			perr.Source = o.source
+		}
+		return err
+	}
+	return postUnmarshal(out)
+}
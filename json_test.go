@@ -0,0 +1,163 @@
+package extyaml_test
+
+import (
+	"math"
+	"net"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type jsonServer struct {
+	Name   string
+	Subnet net.IPNet
+	Port   int
+}
+
+type jsonConfig struct {
+	Name     string
+	Mac      net.HardwareAddr
+	Enabled  bool
+	Ratio    float64
+	Timeout  time.Duration
+	Servers  []jsonServer
+	Labels   map[string]string
+	Sub      *jsonServer
+	Password string `extyaml:"secret"`
+	Hidden   string `skipyamlmarshal:""`
+}
+
+func TestJSONExt(t *testing.T) {
+	cfg := &jsonConfig{
+		Name:     "r1 \"x\"",
+		Mac:      net.HardwareAddr{0x11, 0x22, 0x33, 0x44, 0x55, 0x66},
+		Enabled:  true,
+		Ratio:    0.5,
+		Timeout:  3 * time.Second,
+		Servers:  []jsonServer{{Name: "s1", Subnet: mustCIDR("10.0.0.0/24"), Port: 80}},
+		Labels:   map[string]string{"a": "123", "b": "true"},
+		Password: "p",
+		Hidden:   "h",
+	}
+	buf, err := extyaml.MarshalJSONExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `{"name":"r1 \"x\"","mac":"11:22:33:44:55:66","enabled":true,"ratio":0.5,"timeout":"3s",` +
+		`"servers":[{"name":"s1","subnet":"10.0.0.0/24","port":80}],"labels":{"a":"123","b":"true"},"sub":null,"password":"******"}`
+	if string(buf) != expect {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+
+	buf, err = extyaml.MarshalJSONExt(cfg, extyaml.Unredacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(jsonConfig)
+	err = extyaml.UnmarshalJSONExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != cfg.Name || out.Mac.String() != cfg.Mac.String() || out.Timeout != cfg.Timeout || out.Password != "p" ||
+		out.Servers[0].Subnet.String() != "10.0.0.0/24" || out.Labels["b"] != "true" || out.Hidden != "" {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	def := &jsonConfig{Name: "d", Labels: map[string]string{"a": "123", "c": "x"}}
+	buf, err = extyaml.MarshalJSONExtDefault(&jsonConfig{Name: "d", Enabled: true, Labels: map[string]string{"a": "123"}}, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != `{"enabled":true,"labels":{"c":null}}` {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+
+	err = extyaml.UnmarshalJSONExt([]byte(`{"name": "x",}`), new(jsonConfig))
+	if err == nil || !strings.Contains(err.Error(), "invalid character") {
+		t.Fatalf("expect syntax error, got %v", err)
+	}
+	err = extyaml.UnmarshalJSONExt([]byte(`{"mac": "zz:22:33:44:55:66"}`), new(jsonConfig))
+	if err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expect codec error, got %v", err)
+	}
+	err = extyaml.UnmarshalJSONExt([]byte(`{"servers": [{"port": "80"}]}`), new(jsonConfig))
+	if err == nil || !strings.Contains(err.Error(), "cannot unmarshal !!str `80` into int") {
+		t.Fatalf("expect type error, got %v", err)
+	}
+	err = extyaml.UnmarshalJSONExt([]byte(`{"name": "a\/bé"}`), out)
+	if err != nil || out.Name != "a/bé" {
+		t.Fatalf("unexpected result %v, %v", out.Name, err)
+	}
+}
+
+func TestJSONExtNonFinite(t *testing.T) {
+	for _, f := range []float64{math.Inf(1), math.Inf(-1), math.NaN()} {
+		buf, err := extyaml.MarshalJSONExt(&jsonConfig{Ratio: f})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := new(jsonConfig)
+		err = extyaml.UnmarshalJSONExt(buf, out)
+		if err != nil {
+			t.Fatalf("%v: %v", string(buf), err)
+		}
+		if out.Ratio != f && !(math.IsNaN(f) && math.IsNaN(out.Ratio)) {
+			t.Fatalf("%v: unexpected result %v", string(buf), out.Ratio)
+		}
+	}
+	out := new(jsonConfig)
+	err := extyaml.UnmarshalJSONExt([]byte(`{"ratio": "-Inf", "name": ".inf"}`), out)
+	if err != nil || !math.IsInf(out.Ratio, -1) || out.Name != ".inf" {
+		t.Fatalf("unexpected result %+v, %v", out, err)
+	}
+	err = extyaml.UnmarshalJSONExt([]byte(`{"ratio": "1.5"}`), out)
+	if err == nil {
+		t.Fatal("expect type error for a finite float string")
+	}
+}
+
+func TestJSONExtEncrypted(t *testing.T) {
+	cfg := &encryptConfig{User: "u", Password: "p1", Port: 8080, Tokens: []string{"t1"}}
+	_, err := extyaml.MarshalJSONExt(cfg)
+	if err == nil || !strings.Contains(err.Error(), "no key provider") {
+		t.Fatalf("expect no key provider error, got %v", err)
+	}
+	p, err := extyaml.NewAESGCMKeyProvider([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	extyaml.RegisteredTypes.SetKeyProvider(p)
+	defer extyaml.RegisteredTypes.SetKeyProvider(nil)
+	buf, err := extyaml.MarshalJSONExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain := regexp.MustCompile(`"!enc [^"]+"`).ReplaceAllString(string(buf), `"!enc"`)
+	if !strings.Contains(plain, `"password":"!enc"`) || !strings.Contains(plain, `"port":"!enc"`) || !strings.Contains(plain, `"tokens":["!enc"]`) {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+	out := new(encryptConfig)
+	err = extyaml.UnmarshalJSONExt(buf, out)
+	if err != nil || out.Password != "p1" || out.Port != 8080 || len(out.Tokens) != 1 || out.Tokens[0] != "t1" {
+		t.Fatalf("unexpected result %+v, %v", out, err)
+	}
+
+	buf, err = extyaml.MarshalJSONExtDefault(&encryptConfig{User: "u", Password: "p2"}, &encryptConfig{User: "u"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain = regexp.MustCompile(`"!enc [^"]+"`).ReplaceAllString(string(buf), `"!enc"`)
+	if plain != `{"password":"!enc"}` {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+}
+
+func TestJSONExtDefaultNil(t *testing.T) {
+	_, err := extyaml.MarshalJSONExtDefault(nil, nil)
+	if err == nil {
+		t.Fatal("expect an error for nil in and def")
+	}
+}
@@ -12,7 +12,7 @@ import (
 // addSkipTag add SkipTag for the field that has equal value between in and def
 // note: exported field pkgpath must be "", while unexported field pkgpath can't be ""
 // note2: if there the type is slice/arrary/map, and there some elements in them are same while others are different (e.g. a slice S, which S[0] is same, but others are different), then this function can't mark the whole element as skip since there are some elements are same value
-func addSkipTag(in, def reflect.Value) reflect.Type {
+func addSkipTag(in, def reflect.Value, o *options) reflect.Type {
 	inT := in.Type()
 	if inT.Kind() == reflect.Pointer {
 		inT = inT.Elem()
@@ -26,23 +26,25 @@ func addSkipTag(in, def reflect.Value) reflect.Type {
 		newField := reflect.StructField{
 			Name:    field.Name,
 			Type:    field.Type,
-			PkgPath: inT.PkgPath(),
+			PkgPath: mirrorPkgPath,
 			Tag:     field.Tag,
 			Index:   field.Index,
 		}
-		//inT is anonymous type like an ebeded struct
-		if newField.PkgPath == "" {
-			newField.PkgPath = "anonymous"
+		//an embedded non-export struct, its export fields are promoted
+		embedded := isUnexportedEmbedded(field)
+		if embedded {
+			newField.Name = EmbeddedNamingPrefix + field.Name
+			newField.Tag = RegisteredTypes.mirrorTag(field)
 		}
 
-		if unicode.IsLower([]rune(newField.Name)[0]) {
+		if !embedded && unicode.IsLower([]rune(newField.Name)[0]) {
 			//first char is lower case, no exported field
 			newField.Tag += reflect.StructTag(fmt.Sprintf(` %v:" "`, SkipTag))
 			list = append(list, newField)
 			continue
 		}
 
-		if !field.IsExported() {
+		if !embedded && !field.IsExported() {
 			//a non-export field
 			newField.Tag += reflect.StructTag(fmt.Sprintf(` %v:" "`, SkipTag))
 			list = append(list, newField)
@@ -57,6 +59,11 @@ func addSkipTag(in, def reflect.Value) reflect.Type {
 		fieldType := field.Type
 		inFieldVal := in.Field(i)
 		defFieldVal := def.Field(i)
+		if embedded {
+			//the embedded struct itself is not accessible, use a copy of its export fields
+			inFieldVal = exportedCopy(inFieldVal)
+			defFieldVal = exportedCopy(defFieldVal)
+		}
 		if field.Type.Kind() == reflect.Pointer {
 			if inFieldVal.IsNil() && defFieldVal.IsNil() {
 				//both nil
@@ -102,19 +109,19 @@ func addSkipTag(in, def reflect.Value) reflect.Type {
 		//now non-pointer here, even point has been de-referenced
 		switch fieldType.Kind() {
 		case reflect.Slice, reflect.Map:
-			//empty slice or map are consider equal
-			if inFieldVal.Len() == 0 && defFieldVal.Len() == 0 {
+			//empty slice or map are consider equal, except nil in with ResetNil option, which is restored by a null ResetTag
+			if inFieldVal.Len() == 0 && defFieldVal.Len() == 0 && !isNilReset(inFieldVal, defFieldVal, o) {
 				newField.Tag += reflect.StructTag(fmt.Sprintf(` %v:" "`, SkipTag))
 				list = append(list, newField)
 				continue
 			}
 		}
 		//check  if equal using marshalext
-		inbuf, err := MarshalExt(inFieldVal.Interface())
+		inbuf, err := marshalPlain(inFieldVal.Interface())
 		if err != nil {
 			panic(err)
 		}
-		defbuf, err := MarshalExt(defFieldVal.Interface())
+		defbuf, err := marshalPlain(defFieldVal.Interface())
 		if err != nil {
 			panic(err)
 		}
@@ -144,10 +151,10 @@ func addSkipTag(in, def reflect.Value) reflect.Type {
 			if fieldType.Kind() == reflect.Struct {
 
 				list = append(list, reflect.StructField{
-					Name: field.Name,
-					Type: addSkipTag(inFieldVal, defFieldVal),
+					Name: newField.Name,
+					Type: addSkipTag(inFieldVal, defFieldVal, o),
 					// PkgPath: inT.PkgPath(),
-					Tag:   field.Tag,
+					Tag:   newField.Tag,
 					Index: field.Index,
 				})
 			}
@@ -156,17 +163,49 @@ func addSkipTag(in, def reflect.Value) reflect.Type {
 	return reflect.StructOf(list)
 }
 
-// MarshalExtDefault marshal in struct into YAML bytes, any field that has same corresponding value as def will be omitted in output.sss
+// ResetNil is an Option of MarshalExtDefault that writes a nil pointer, slice or map whose default is not nil
+// as a null value with ResetTag, so that UnmarshalExtDefault restores nil instead of an empty value
+func ResetNil() Option {
+	return func(o *options) {
+		o.resetNil = true
+	}
+}
+
+// MarshalExtDefault marshal in struct into YAML bytes, any field that has same corresponding value as def will be omitted in output.
+// For map fields, only changed and added keys are in output, a deleted key has a null value with ResetTag;
+// for slice of struct fields with `extyaml:"mergekey=<yaml key>"`, only changed elements and their changed fields are in output.
+// The output applied on top of def via UnmarshalExtDefault or MergeExt results in in, except that a nil pointer, slice or map
+// of in whose default is not nil is written as null, [] or {}; with ResetNil option, it is written as a null value with ResetTag
+// that restores nil, such output can only be loaded by UnmarshalExtDefault or MergeExt.
+// Secret fields are redacted and encrypted fields are encrypted the same way as MarshalExt.
 // in and def must be same type of struct
-func MarshalExtDefault(in, def any) ([]byte, error) {
+func MarshalExtDefault(in, def any, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	n, err := marshalDefaultNode(in, def, o)
+	if err != nil {
+		return nil, err
+	}
+	inT := indirectType(reflect.TypeOf(in))
+	err = RegisteredTypes.encryptValues(n, inT)
+	if err != nil {
+		return nil, err
+	}
+	if !o.unredacted {
+		redact(n, inT)
+	}
+	return yaml.Marshal(n)
+}
+
+// marshalDefaultNode marshal in into a node without the fields that are same as def, see MarshalExtDefault
+func marshalDefaultNode(in, def any, o *options) (*yaml.Node, error) {
 	if reflect.TypeOf(in) != reflect.TypeOf(def) {
 		return nil, fmt.Errorf("in and def are not same type")
 	}
 	inT := reflect.TypeOf(in)
-	if inT.Kind() == reflect.Pointer {
+	if inT != nil && inT.Kind() == reflect.Pointer {
 		inT = inT.Elem()
 	}
-	if inT.Kind() != reflect.Struct {
+	if inT == nil || inT.Kind() != reflect.Struct {
 		return nil, fmt.Errorf("in and def are not struct")
 	}
 
@@ -178,9 +217,256 @@ func MarshalExtDefault(in, def any) ([]byte, error) {
 	if defV.Kind() == reflect.Pointer {
 		defV = defV.Elem()
 	}
-	newT := addSkipTag(inV, defV)
+	newT := addSkipTag(inV, defV, o)
 	newType := convertStructType(newT)
 	newVal := reflect.New(newType)
-	translateStructInline(inV.Interface(), newVal.Interface(), "", true)
-	return yaml.Marshal(newVal.Interface())
+	translateStructInline(inV.Interface(), newVal.Interface(), "", true, newOptions(nil))
+	n := new(yaml.Node)
+	err := n.Encode(newVal.Interface())
+	if err != nil {
+		return nil, err
+	}
+	spliceRemain(n)
+	defN, err := marshalNode(defV.Interface(), newOptions(nil))
+	if err != nil {
+		return nil, err
+	}
+	trimDefault(n, defN, inV, defV, o)
+	return n, nil
+}
+
+// nodeEqual returns true if a and b represent the same value
+func nodeEqual(a, b *yaml.Node) bool {
+	if a.Kind != b.Kind || a.ShortTag() != b.ShortTag() || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	for i := range a.Content {
+		if !nodeEqual(a.Content[i], b.Content[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// newResetNode returns a null node with ResetTag, which marks a deleted key
+func newResetNode() *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: ResetTag}
+}
+
+// isNilReset returns true if in is a nil pointer, map or slice but def is not, and ResetNil option is specified;
+// the difference is then a null value with ResetTag, which restores nil
+func isNilReset(in, def reflect.Value, o *options) bool {
+	if !o.resetNil || !in.IsValid() || !def.IsValid() {
+		return false
+	}
+	switch in.Kind() {
+	case reflect.Pointer, reflect.Map, reflect.Slice:
+		return in.IsNil() && !def.IsNil()
+	}
+	return false
+}
+
+// mapValue returns the value of key k in map v, an invalid value if there is no such key or key is not a string
+func mapValue(v reflect.Value, k string) reflect.Value {
+	if !v.IsValid() || v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}
+	}
+	return v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+}
+
+// indirectValue returns the value v points to, an invalid value if v is a nil pointer
+func indirectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// trimDefault replaces map fields and slice of struct fields with mergekey in mapping node n
+// with the element-wise difference from def, inV and defV are the Go struct values of n and def
+func trimDefault(n, def *yaml.Node, inV, defV reflect.Value, o *options) {
+	inV, defV = indirectValue(inV), indirectValue(defV)
+	if n.Kind != yaml.MappingNode || def == nil || def.Kind != yaml.MappingNode || !inV.IsValid() || !defV.IsValid() ||
+		inV.Kind() != reflect.Struct || isCodecType(inV.Type()) {
+		return
+	}
+	t := inV.Type()
+	content := n.Content[:0:0]
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		f, ok := yamlFieldByKey(t, k.Value)
+		if !ok {
+			content = append(content, k, v)
+			continue
+		}
+		fv, dfv := fieldByIndex(inV, f.index, false), fieldByIndex(defV, f.index, false)
+		if isNilReset(fv, dfv, o) {
+			content = append(content, k, newResetNode())
+			continue
+		}
+		dv := mappingValue(def, k.Value)
+		ft := indirectType(f.field.Type)
+		st, _ := getMergeStrategy(f.field)
+		switch {
+		case ft.Kind() == reflect.Map && !isCodecType(ft) && st.strategy != MergeReplace,
+			ft.Kind() == reflect.Slice && st.strategy == MergeByKey && st.key != "":
+			d, changed := diffNode(v, dv, fv, dfv, ft, st, o)
+			if !changed {
+				continue
+			}
+			v = d
+		case ft.Kind() == reflect.Struct:
+			trimDefault(v, dv, fv, dfv, o)
+		}
+		content = append(content, k, v)
+	}
+	n.Content = content
+}
+
+// diffNode returns the difference of n from def, which applied on top of def via MergeExt results in n;
+// changed is false if there is no difference; inV and defV are the Go values of n and def, invalid if unknown,
+// t is the Go type n maps to, st is the merge strategy of n
+func diffNode(n, def *yaml.Node, inV, defV reflect.Value, t reflect.Type, st mergeStrategy, o *options) (r *yaml.Node, changed bool) {
+	if def == nil {
+		return n, true
+	}
+	if isNilReset(inV, defV, o) {
+		return newResetNode(), true
+	}
+	if nodeEqual(n, def) {
+		return nil, false
+	}
+	t = indirectType(t)
+	inV, defV = indirectValue(inV), indirectValue(defV)
+	if n.Kind != def.Kind || isCodecType(t) {
+		return n, true
+	}
+	switch {
+	case n.Kind == yaml.MappingNode && (t.Kind() == reflect.Struct || t.Kind() == reflect.Map && st.strategy != MergeReplace):
+		r = &yaml.Node{Kind: yaml.MappingNode, Tag: n.Tag, Style: n.Style}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			vt, vst := t, mergeStrategy{}
+			var fv, dfv reflect.Value
+			if t.Kind() == reflect.Struct {
+				f, ok := yamlFieldByKey(t, k.Value)
+				if !ok {
+					r.Content = append(r.Content, k, v)
+					continue
+				}
+				vt = f.field.Type
+				vst, _ = getMergeStrategy(f.field)
+				if inV.IsValid() && defV.IsValid() {
+					fv, dfv = fieldByIndex(inV, f.index, false), fieldByIndex(defV, f.index, false)
+				}
+			} else {
+				vt = t.Elem()
+				fv, dfv = mapValue(inV, k.Value), mapValue(defV, k.Value)
+			}
+			if d, ch := diffNode(v, mappingValue(def, k.Value), fv, dfv, vt, vst, o); ch {
+				r.Content = append(r.Content, k, d)
+			}
+		}
+		for i := 0; i+1 < len(def.Content); i += 2 {
+			if keyIndex(n, def.Content[i].Value) < 0 {
+				//deleted key
+				r.Content = append(r.Content, def.Content[i], newResetNode())
+			}
+		}
+		return r, true
+	case n.Kind == yaml.SequenceNode && t.Kind() == reflect.Slice && st.strategy == MergeByKey && st.key != "":
+		if r, ok := diffByKey(n, def, inV, defV, t.Elem(), st.key, o); ok {
+			return r, true
+		}
+		//can't be expressed as merging by key, replace it
+		r = &yaml.Node{}
+		*r = *n
+		r.Tag = ResetTag
+		return r, true
+	}
+	return n, true
+}
+
+// diffByKey returns the difference of sequence n from def, elements are identified by the value of YAML key;
+// ok is false if n can't be result of merging the difference into def, e.g. an element of def is removed;
+// inV and defV are the Go slices of n and def, invalid if unknown
+func diffByKey(n, def *yaml.Node, inV, defV reflect.Value, et reflect.Type, key string, o *options) (r *yaml.Node, ok bool) {
+	r = &yaml.Node{Kind: yaml.SequenceNode, Tag: n.Tag, Style: n.Style}
+	if len(n.Content) < len(def.Content) {
+		return nil, false
+	}
+	for i, item := range n.Content {
+		kv := mappingValue(item, key)
+		if kv == nil {
+			return nil, false
+		}
+		if i >= len(def.Content) {
+			//new element
+			for _, ditem := range def.Content {
+				if dkv := mappingValue(ditem, key); dkv != nil && dkv.Value == kv.Value {
+					return nil, false
+				}
+			}
+			r.Content = append(r.Content, item)
+			continue
+		}
+		ditem := def.Content[i]
+		if dkv := mappingValue(ditem, key); dkv == nil || dkv.Value != kv.Value {
+			//element removed or reordered
+			return nil, false
+		}
+		var iv, dv reflect.Value
+		if inV.IsValid() && defV.IsValid() && i < inV.Len() && i < defV.Len() {
+			iv, dv = inV.Index(i), defV.Index(i)
+		}
+		if isNilReset(iv, dv, o) {
+			return nil, false
+		}
+		d, changed := diffNode(item, ditem, iv, dv, et, mergeStrategy{}, o)
+		if !changed {
+			continue
+		}
+		if d.Kind == yaml.MappingNode && keyIndex(d, key) < 0 {
+			//the key identifies the element
+			d.Content = append([]*yaml.Node{item.Content[keyIndex(item, key)], kv}, d.Content...)
+		}
+		r.Content = append(r.Content, d)
+	}
+	return r, true
+}
+
+// UnmarshalExtDefault is the inverse of MarshalExtDefault, out is set to a deep copy of def, then buf is applied on top of it
+// the same way as MergeExt; so that UnmarshalExtDefault(MarshalExtDefault(in, def), out, def) results in in.
+// out must be a pointer to the type of def, out.PostUnmarshal() gets called at the end if out implements PostUnmarshal interface
+func UnmarshalExtDefault(buf []byte, out, def any, opts ...Option) error {
+	outV := reflect.ValueOf(out)
+	if outV.Kind() != reflect.Pointer || outV.IsNil() {
+		return fmt.Errorf("the object unmarhsal into is not a pointer")
+	}
+	defV := reflect.ValueOf(def)
+	if defV.Kind() == reflect.Pointer {
+		defV = defV.Elem()
+	}
+	if defV.Type() != outV.Type().Elem() {
+		return fmt.Errorf("def is %v, not the type out points to", defV.Type())
+	}
+	o := newOptions(opts)
+	defN, err := marshalNode(defV.Interface(), o)
+	if err != nil {
+		return err
+	}
+	doc := new(yaml.Node)
+	err = yaml.Unmarshal(buf, doc)
+	if err != nil {
+		return err
+	}
+	outV.Elem().Set(deepCopy(defV))
+	err = mergeNodes(out, []*yaml.Node{{Kind: yaml.DocumentNode, Content: []*yaml.Node{defN}}, doc}, o)
+	if err != nil {
+		return err
+	}
+	return postUnmarshal(out)
 }
@@ -3,7 +3,11 @@ package extyaml_test
 import (
 	"bytes"
 	"fmt"
+	"math/rand"
+	"net"
+	"reflect"
 	"testing"
+	"testing/quick"
 	"time"
 
 	"github.com/hujun-open/extyaml"
@@ -165,3 +169,255 @@ func TestMarshalDefault(t *testing.T) {
 	}
 
 }
+
+type elemServer struct {
+	Name   string
+	Port   int
+	Labels map[string]string
+}
+
+type elemConfig struct {
+	Labels  map[string]int
+	Servers []elemServer `extyaml:"mergekey=name"`
+	Ports   []int
+}
+
+func TestMarshalDefaultElementWise(t *testing.T) {
+	def := elemConfig{
+		Labels: map[string]int{"a": 1, "b": 2, "c": 3},
+		Servers: []elemServer{
+			{Name: "s1", Port: 80, Labels: map[string]string{"x": "1"}},
+			{Name: "s2", Port: 80},
+		},
+		Ports: []int{1, 2},
+	}
+	in := elemConfig{
+		Labels: map[string]int{"a": 1, "b": 20, "d": 4},
+		Servers: []elemServer{
+			{Name: "s1", Port: 80, Labels: map[string]string{"x": "1", "y": "2"}},
+			{Name: "s2", Port: 80},
+			{Name: "s3", Port: 81},
+		},
+		Ports: []int{1, 2},
+	}
+	buf, err := extyaml.MarshalExtDefault(in, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `labels:
+    b: 20
+    d: 4
+    c: !reset
+servers:
+    - name: s1
+      labels:
+        "y": "2"
+    - name: s3
+      port: 81
+      labels: {}
+`
+	if string(buf) != expected {
+		t.Fatalf("MarshalExtDefault result %v is different from expected %v", string(buf), expected)
+	}
+	check := func(buf []byte) {
+		t.Helper()
+		out := def
+		out.Labels = map[string]int{"a": 1, "b": 2, "c": 3}
+		out.Servers = append([]elemServer(nil), def.Servers...)
+		out.Servers[0].Labels = map[string]string{"x": "1"}
+		defBuf, err := extyaml.MarshalExt(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = extyaml.MergeExt(&out, defBuf, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		inBuf, _ := extyaml.MarshalExt(in)
+		outBuf, _ := extyaml.MarshalExt(out)
+		if string(inBuf) != string(outBuf) {
+			t.Fatalf("merge result %v is different from input %v", string(outBuf), string(inBuf))
+		}
+	}
+	check(buf)
+
+	//an element is removed, the whole slice is replaced
+	in.Servers = in.Servers[1:]
+	buf, err = extyaml.MarshalExtDefault(in, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `labels:
+    b: 20
+    d: 4
+    c: !reset
+servers: !reset
+    - name: s2
+      port: 80
+      labels: {}
+    - name: s3
+      port: 81
+      labels: {}
+`
+	if string(buf) != expected {
+		t.Fatalf("MarshalExtDefault result %v is different from expected %v", string(buf), expected)
+	}
+	check(buf)
+
+	//deleted key marker also works with MergeExt
+	out := def
+	out.Labels = map[string]int{"a": 1, "b": 2, "c": 3}
+	err = extyaml.MergeExt(&out, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Labels) != 3 || out.Labels["b"] != 20 || out.Labels["d"] != 4 || len(out.Servers) != 2 {
+		t.Fatalf("unexpected unmarshal result %+v", out)
+	}
+}
+
+type propSub struct {
+	Subnet net.IPNet
+	Port   *int
+}
+
+type propConfig struct {
+	Name    string
+	Count   int
+	Ptr     *int
+	Sub     propSub
+	SubPtr  *propSub
+	Labels  map[string]int
+	SubMap  map[string]propSub
+	Ints    []int
+	Servers []elemServer `extyaml:"mergekey=name"`
+}
+
+func (propConfig) Generate(r *rand.Rand, size int) reflect.Value {
+	intPtr := func() *int {
+		if r.Intn(3) == 0 {
+			return nil
+		}
+		i := r.Intn(3)
+		return &i
+	}
+	sub := func() propSub {
+		return propSub{
+			Subnet: mustCIDR(fmt.Sprintf("10.%d.0.0/16", r.Intn(3))),
+			Port:   intPtr(),
+		}
+	}
+	c := propConfig{
+		Name:  []string{"", "a", "b"}[r.Intn(3)],
+		Count: r.Intn(3),
+		Ptr:   intPtr(),
+		Sub:   sub(),
+	}
+	if r.Intn(2) == 0 {
+		s := sub()
+		c.SubPtr = &s
+	}
+	if n := r.Intn(4); n > 0 {
+		c.Labels = map[string]int{}
+		c.SubMap = map[string]propSub{}
+		for i := 0; i < n; i++ {
+			c.Labels[fmt.Sprint("l", r.Intn(4))] = r.Intn(3)
+			c.SubMap[fmt.Sprint("s", r.Intn(4))] = sub()
+		}
+	}
+	for i := r.Intn(4); i > 0; i-- {
+		c.Ints = append(c.Ints, r.Intn(3))
+	}
+	for _, i := range r.Perm(4)[:r.Intn(5)] {
+		s := elemServer{Name: fmt.Sprint("s", i), Port: r.Intn(2)}
+		if r.Intn(2) == 0 {
+			s.Labels = map[string]string{fmt.Sprint("k", r.Intn(2)): fmt.Sprint(r.Intn(2))}
+		}
+		c.Servers = append(c.Servers, s)
+	}
+	return reflect.ValueOf(c)
+}
+
+func TestUnmarshalExtDefault(t *testing.T) {
+	roundTrip := func(in, def propConfig) bool {
+		//ResetNil restores nil pointers, slices and maps whose default is not nil
+		buf, err := extyaml.MarshalExtDefault(in, def, extyaml.ResetNil())
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		defBuf, err := extyaml.MarshalExt(def)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		out := new(propConfig)
+		err = extyaml.UnmarshalExtDefault(buf, out, def)
+		if err != nil {
+			t.Log(err)
+			return false
+		}
+		if !reflect.DeepEqual(in, *out) {
+			t.Logf("default:\n%v\ndiff:\n%v\nresult:\n%#v\nexpected:\n%#v", string(defBuf), string(buf), *out, in)
+			return false
+		}
+		//def is not changed
+		newDefBuf, _ := extyaml.MarshalExt(def)
+		if !bytes.Equal(defBuf, newDefBuf) {
+			t.Logf("default is changed to %v", string(newDefBuf))
+			return false
+		}
+		return true
+	}
+	err := quick.Check(roundTrip, &quick.Config{MaxCount: 200, Rand: rand.New(rand.NewSource(1))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	//out doesn't share pointer and map with def
+	def := propConfig{Ptr: new(int), Labels: map[string]int{"a": 1}}
+	out := new(propConfig)
+	err = extyaml.UnmarshalExtDefault([]byte("count: 1\n"), out, &def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*out.Ptr = 2
+	out.Labels["b"] = 2
+	if *def.Ptr != 0 || len(def.Labels) != 1 || out.Count != 1 {
+		t.Fatalf("unexpected result %+v, default %+v", out, def)
+	}
+}
+
+func TestMarshalExtDefaultNil(t *testing.T) {
+	def := propConfig{Count: 1, Ptr: new(int), SubPtr: &propSub{}, Ints: []int{1, 2}}
+	in := propConfig{Count: 2}
+	buf, err := extyaml.MarshalExtDefault(in, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "count: 2\nptr: null\nsubptr: null\nints: []\n" {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+	//the output is loaded by UnmarshalExt on top of def
+	out := def
+	err = extyaml.UnmarshalExt(buf, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//UnmarshalExt overwrites the first elements of an existing slice, so an empty sequence keeps it
+	if out.Count != 2 || out.Ptr != nil || out.SubPtr != nil || !reflect.DeepEqual(out.Ints, def.Ints) {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	buf, err = extyaml.MarshalExtDefault(in, def, extyaml.ResetNil())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "count: 2\nptr: !reset\nsubptr: !reset\nints: !reset\n" {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+	out = propConfig{}
+	err = extyaml.UnmarshalExtDefault(buf, &out, def)
+	if err != nil || !reflect.DeepEqual(in, out) {
+		t.Fatalf("unexpected result %+v, %v", out, err)
+	}
+}
@@ -0,0 +1,406 @@
+package extyaml
+
+import (
+	"fmt"
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ResetTag is the YAML tag to clear the value inherited from previous documents in MergeExt,
+// e.g. "peers: !reset"; if the tagged value is not null, it replaces the inherited value regardless of the merge strategy
+const ResetTag = "!reset"
+
+// merge strategies, specified via ExtTag, e.g. `extyaml:"merge=append"` or `extyaml:"mergekey=name"`
+const (
+	//MergeReplace replaces the whole slice/map, this is the default for slice and array
+	MergeReplace = "replace"
+	//MergeAppend appends the slice elements
+	MergeAppend = "append"
+	//MergeByKey merges the map entries, this is the default for map;
+	//for slice of struct, use `extyaml:"mergekey=<yaml key>"`, elements having same value of the key are merged
+	MergeByKey = "merge"
+)
+
+type mergeStrategy struct {
+	strategy string
+	//key is the YAML key identifies a slice element for MergeByKey
+	key string
+}
+
+func getMergeStrategy(field reflect.StructField) (mergeStrategy, error) {
+	opts := parseExtTag(field.Tag)
+	r := mergeStrategy{strategy: opts["merge"], key: opts["mergekey"]}
+	if r.key != "" {
+		if r.strategy != "" && r.strategy != MergeByKey {
+			return r, fmt.Errorf("field %v: mergekey can only be used with %v strategy", field.Name, MergeByKey)
+		}
+		r.strategy = MergeByKey
+	}
+	switch r.strategy {
+	case "", MergeReplace, MergeAppend, MergeByKey:
+	default:
+		return r, fmt.Errorf("field %v: unknown merge strategy %v", field.Name, r.strategy)
+	}
+	return r, nil
+}
+
+// merger merges YAML documents
+type merger struct {
+	//resets is the list of key paths that have ResetTag, they need to be cleared after unmarshalling
+	resets [][]*yaml.Node
+}
+
+// isReset returns true if n is a null value with ResetTag
+func isReset(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.Tag == ResetTag && n.Value == ""
+}
+
+// keyIndex returns index of key in mapping node n, -1 if not found
+func keyIndex(n *yaml.Node, key string) int {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mappingValue returns the value of key in mapping node n, nil if not found
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode {
+		return nil
+	}
+	if i := keyIndex(n, key); i >= 0 {
+		return n.Content[i+1]
+	}
+	return nil
+}
+
+// clearTag removes ResetTag from n so it is decoded as normal value
+func clearTag(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		n.Tag = "!!map"
+	case yaml.SequenceNode:
+		n.Tag = "!!seq"
+	default:
+		n.Tag = ""
+	}
+	n.Style &^= yaml.TaggedStyle
+}
+
+// merge merges src on top of dst and returns the result, both of them maps to Go type t;
+// path is the list of key nodes from the document root, nil if the value is decoded from scratch
+func (m *merger) merge(dst, src *yaml.Node, t reflect.Type, st mergeStrategy, path []*yaml.Node) (*yaml.Node, error) {
+	if src.Tag == ResetTag {
+		//not null here, replace inherited value
+		clean(src)
+		return src, nil
+	}
+	if dst == nil || dst.Kind != src.Kind || src.Kind == yaml.ScalarNode || src.Kind == yaml.AliasNode {
+		clean(src)
+		return src, nil
+	}
+	t = indirectType(t)
+	if isCodecType(t) {
+		return src, nil
+	}
+	switch src.Kind {
+	case yaml.MappingNode:
+		switch t.Kind() {
+		case reflect.Struct:
+			return m.mergeMapping(dst, src, func(key string) (reflect.Type, mergeStrategy, error) {
+				f, ok := yamlFieldByKey(t, key)
+				if !ok {
+					return nil, mergeStrategy{}, nil
+				}
+				fst, err := getMergeStrategy(f.field)
+				return f.field.Type, fst, err
+			}, path)
+		case reflect.Map:
+			if st.strategy == MergeReplace {
+				clean(src)
+				return src, nil
+			}
+			return m.mergeMapping(dst, src, func(string) (reflect.Type, mergeStrategy, error) {
+				return t.Elem(), mergeStrategy{}, nil
+			}, path)
+		}
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			break
+		}
+		switch st.strategy {
+		case MergeAppend:
+			clean(src)
+			dst.Content = append(dst.Content, src.Content...)
+			return dst, nil
+		case MergeByKey:
+			if st.key == "" {
+				break
+			}
+			for _, item := range src.Content {
+				kv := mappingValue(item, st.key)
+				merged := false
+				if kv != nil {
+					for i, ditem := range dst.Content {
+						dkv := mappingValue(ditem, st.key)
+						if dkv != nil && dkv.Value == kv.Value {
+							r, err := m.merge(ditem, item, t.Elem(), mergeStrategy{}, nil)
+							if err != nil {
+								return nil, err
+							}
+							dst.Content[i] = r
+							merged = true
+							break
+						}
+					}
+				}
+				if !merged {
+					clean(item)
+					dst.Content = append(dst.Content, item)
+				}
+			}
+			return dst, nil
+		}
+	}
+	clean(src)
+	return src, nil
+}
+
+// mergeMapping merges mapping node src into dst, fieldFunc returns Go type and merge strategy of the value of a key,
+// nil type if the key is unknown
+func (m *merger) mergeMapping(dst, src *yaml.Node, fieldFunc func(key string) (reflect.Type, mergeStrategy, error), path []*yaml.Node) (*yaml.Node, error) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		k, v := src.Content[i], src.Content[i+1]
+		ft, st, err := fieldFunc(k.Value)
+		if err != nil {
+			return nil, err
+		}
+		di := keyIndex(dst, k.Value)
+		if isReset(v) {
+			if di >= 0 {
+				dst.Content = append(dst.Content[:di], dst.Content[di+2:]...)
+			}
+			if path != nil {
+				m.resets = append(m.resets, append(append([]*yaml.Node{}, path...), k))
+			}
+			continue
+		}
+		var childPath []*yaml.Node
+		if path != nil {
+			childPath = append(append([]*yaml.Node{}, path...), k)
+		}
+		if ft == nil {
+			//unknown key, keep the latest
+			if di >= 0 {
+				dst.Content[di+1] = v
+			} else {
+				dst.Content = append(dst.Content, k, v)
+			}
+			continue
+		}
+		var dv *yaml.Node
+		if di >= 0 {
+			dv = dst.Content[di+1]
+		} else if v.Kind == yaml.MappingNode {
+			//merge with an empty mapping so that ResetTag in v still clears the value in out
+			dv = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		}
+		r, err := m.merge(dv, v, ft, st, childPath)
+		if err != nil {
+			return nil, err
+		}
+		if di >= 0 {
+			dst.Content[di+1] = r
+		} else {
+			dst.Content = append(dst.Content, k, r)
+		}
+	}
+	return dst, nil
+}
+
+// clean removes the ResetTag in n that has no value to reset, n is decoded from scratch
+func clean(n *yaml.Node) {
+	if n.Tag == ResetTag {
+		clearTag(n)
+	}
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		for i := 0; i < len(n.Content); i++ {
+			if n.Kind == yaml.MappingNode && i%2 == 1 && isReset(n.Content[i]) {
+				//nothing to reset, remove the key
+				n.Content = append(n.Content[:i-1], n.Content[i+1:]...)
+				i -= 2
+				continue
+			}
+			clean(n.Content[i])
+		}
+	}
+}
+
+// collectResets removes the keys that have null value with ResetTag in mapping node n, and returns their key paths;
+// path is the list of key nodes from the document root to n
+func collectResets(n *yaml.Node, path []*yaml.Node) [][]*yaml.Node {
+	var r [][]*yaml.Node
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			r = append(r, collectResets(c, path)...)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], n.Content[i+1]
+			childPath := append(append([]*yaml.Node{}, path...), k)
+			if isReset(v) {
+				r = append(r, childPath)
+				n.Content = append(n.Content[:i], n.Content[i+2:]...)
+				i -= 2
+				continue
+			}
+			r = append(r, collectResets(v, childPath)...)
+		}
+	}
+	return r
+}
+
+// reset clears the value in v specified by key path
+func reset(v reflect.Value, path []*yaml.Node) error {
+	for len(path) > 0 {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return nil
+			}
+			v = v.Elem()
+		}
+		key := path[0]
+		switch v.Kind() {
+		case reflect.Struct:
+			f, ok := yamlFieldByKey(v.Type(), key.Value)
+			if !ok {
+				return nil
+			}
+			v = v.FieldByIndex(f.index)
+			if len(path) == 1 {
+				if !v.CanSet() {
+					return nil
+				}
+				v.Set(reflect.Zero(v.Type()))
+				return nil
+			}
+		case reflect.Map:
+			if v.IsNil() {
+				return nil
+			}
+			k := reflect.New(v.Type().Key())
+			err := unmarshalNode(key, k.Interface(), newOptions(nil))
+			if err != nil {
+				return err
+			}
+			if len(path) == 1 {
+				v.SetMapIndex(k.Elem(), reflect.Value{})
+				return nil
+			}
+			//map element is not addressable, reset a copy then set it back
+			elem := reflect.New(v.Type().Elem()).Elem()
+			if e := v.MapIndex(k.Elem()); e.IsValid() {
+				elem.Set(e)
+			} else {
+				return nil
+			}
+			err = reset(elem, path[1:])
+			if err != nil {
+				return err
+			}
+			v.SetMapIndex(k.Elem(), elem)
+			return nil
+		default:
+			return nil
+		}
+		path = path[1:]
+	}
+	return nil
+}
+
+// MergeExt unmarshal docs into out one by one, each document is applied on top of the result of previous documents;
+// out must be a pointer, fields not set in any of docs are left untouched.
+// For slice and map fields, the merge strategy could be specified via ExtTag:
+//   - `extyaml:"merge=replace"`: replace the inherited value, this is the default for slice and array
+//   - `extyaml:"merge=append"`: append to the inherited slice
+//   - `extyaml:"merge=merge"`: merge with the inherited map by key, this is the default for map
+//   - `extyaml:"mergekey=name"`: merge with the inherited slice of struct, elements that have same value of YAML key "name" are merged
+//
+// A value tagged with ResetTag clears the inherited value.
+// out.PostUnmarshal() gets called at the end if out implements PostUnmarshal interface.
+func MergeExt(out any, docs ...[]byte) error {
+	return MergeExtWithOptions(out, docs)
+}
+
+// MergeExtWithOptions is MergeExt with options, e.g. OnWarning
+func MergeExtWithOptions(out any, docs [][]byte, opts ...Option) error {
+	var nodes []*yaml.Node
+	for i, buf := range docs {
+		doc := new(yaml.Node)
+		err := yaml.Unmarshal(buf, doc)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", i, err)
+		}
+		nodes = append(nodes, doc)
+	}
+	err := mergeNodes(out, nodes, newOptions(opts))
+	if err != nil {
+		return err
+	}
+	return postUnmarshal(out)
+}
+
+// mergeNodes unmarshal YAML documents docs into out one by one, without calling PostUnmarshal;
+// warnings are reported for each parsed document, a generated document (e.g. marshaled default) has no position and reports no warning
+func mergeNodes(out any, docs []*yaml.Node, o *options) error {
+	if reflect.TypeOf(out).Kind() != reflect.Pointer {
+		return fmt.Errorf("the object unmarhsal into is not a pointer")
+	}
+	t := reflect.TypeOf(out).Elem()
+	m := new(merger)
+	result := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	applied := false
+	for i, doc := range docs {
+		if len(doc.Content) == 0 {
+			continue
+		}
+		err := RegisteredTypes.foldKeys(doc, t)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", i, err)
+		}
+		do := o
+		if doc.Line == 0 {
+			do = o.quiet()
+		}
+		err = applyAliases(doc, t, do)
+		if err != nil {
+			return fmt.Errorf("document %d: %w", i, err)
+		}
+		result, err = m.merge(result, doc.Content[0], t, mergeStrategy{}, []*yaml.Node{})
+		if err != nil {
+			return fmt.Errorf("document %d: %w", i, err)
+		}
+		applied = true
+	}
+	if applied {
+		//warnings are already reported for each document
+		mo := o.quiet()
+		mo.merging = true
+		err := unmarshalNode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{result}}, out, mo)
+		if err != nil {
+			return err
+		}
+	}
+	for _, p := range m.resets {
+		err := reset(reflect.ValueOf(out), p)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
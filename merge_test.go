@@ -0,0 +1,133 @@
+package extyaml_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type mergeServer struct {
+	Name   string
+	Subnet net.IPNet
+	Port   int
+}
+
+type mergeConfig struct {
+	Name     string
+	Level    int
+	Tags     []string `extyaml:"merge=append"`
+	Ports    []int
+	Servers  []mergeServer `extyaml:"mergekey=name"`
+	Peers    map[string]int
+	Replaced map[string]int `extyaml:"merge=replace"`
+	Mac      net.HardwareAddr
+}
+
+func TestMergeExt(t *testing.T) {
+	defaults := []byte(`name: default
+level: 1
+tags: [a]
+ports: [1, 2]
+servers:
+  - name: s1
+    subnet: 10.0.0.0/8
+    port: 80
+  - name: s2
+    subnet: 10.1.0.0/16
+peers: {p1: 1, p2: 2}
+replaced: {r1: 1}
+mac: 11:22:33:44:55:66
+`)
+	site := []byte(`name: site
+tags: [b]
+ports: [3]
+servers:
+  - name: s2
+    port: 8080
+  - name: s3
+    subnet: 10.3.0.0/16
+peers: {p2: 20, p3: 3}
+replaced: {r2: 2}
+`)
+	host := []byte(`level: !reset
+tags: [c]
+peers:
+  p1: !reset
+mac: !reset
+`)
+	cfg := &mergeConfig{Level: 100}
+	err := extyaml.MergeExt(cfg, defaults, site, host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "site" || cfg.Level != 0 || cfg.Mac != nil {
+		t.Fatalf("unexpected scalar result %+v", cfg)
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"a", "b", "c"}) {
+		t.Fatalf("unexpected appended tags %v", cfg.Tags)
+	}
+	if !reflect.DeepEqual(cfg.Ports, []int{3}) {
+		t.Fatalf("unexpected replaced ports %v", cfg.Ports)
+	}
+	if !reflect.DeepEqual(cfg.Peers, map[string]int{"p2": 20, "p3": 3}) {
+		t.Fatalf("unexpected merged peers %v", cfg.Peers)
+	}
+	if !reflect.DeepEqual(cfg.Replaced, map[string]int{"r2": 2}) {
+		t.Fatalf("unexpected replaced map %v", cfg.Replaced)
+	}
+	if len(cfg.Servers) != 3 {
+		t.Fatalf("unexpected servers %+v", cfg.Servers)
+	}
+	s2 := cfg.Servers[1]
+	if s2.Name != "s2" || s2.Port != 8080 || s2.Subnet.String() != "10.1.0.0/16" {
+		t.Fatalf("unexpected merged server %+v", s2)
+	}
+	if cfg.Servers[2].Subnet.String() != "10.3.0.0/16" {
+		t.Fatalf("unexpected appended server %+v", cfg.Servers[2])
+	}
+
+	//reset with a value replaces the inherited value regardless of merge strategy
+	cfg = new(mergeConfig)
+	err = extyaml.MergeExt(cfg, defaults, []byte("tags: !reset [x]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"x"}) {
+		t.Fatalf("unexpected reset tags %v", cfg.Tags)
+	}
+
+	type badConfig struct {
+		Tags []string `extyaml:"merge=unknown"`
+	}
+	err = extyaml.MergeExt(new(badConfig), []byte("tags: [a]"), []byte("tags: [b]"))
+	if err == nil {
+		t.Fatal("expect error for unknown merge strategy")
+	}
+}
+
+func TestUnmarshalExtSliceSemantics(t *testing.T) {
+	//UnmarshalExt overwrites the first elements of an existing slice, and doesn't honor ResetTag
+	cfg := mergeConfig{Ports: []int{1, 2, 3}, Peers: map[string]int{"a": 1}}
+	err := extyaml.UnmarshalExt([]byte("ports: [4]\n"), &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Ports, []int{4, 2, 3}) {
+		t.Fatalf("unexpected ports %v", cfg.Ports)
+	}
+	err = extyaml.UnmarshalExt([]byte("peers: !reset\n"), &cfg)
+	if err == nil {
+		t.Fatalf("expect an error of !reset, got %+v", cfg)
+	}
+	//MergeExt replaces the slice
+	cfg = mergeConfig{Ports: []int{1, 2, 3}}
+	err = extyaml.MergeExt(&cfg, []byte("ports: [4]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Ports, []int{4}) {
+		t.Fatalf("unexpected ports %v", cfg.Ports)
+	}
+}
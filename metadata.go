@@ -0,0 +1,90 @@
+package extyaml
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// FieldMeta is the provenance of a value set by unmarshalling
+type FieldMeta struct {
+	//Path is the path of the value, e.g. "servers[1].port"
+	Path string
+	//Source is the name of source document, empty if unknown
+	Source       string
+	Line, Column int
+}
+
+// Metadata records the provenance of all values present in the unmarshalled YAML document
+type Metadata struct {
+	fields map[string]FieldMeta
+	paths  []string
+}
+
+// WithMetadata makes unmarshalling record the provenance of values into md, existing records of md are cleared
+func WithMetadata(md *Metadata) Option {
+	return func(o *options) {
+		o.metadata = md
+	}
+}
+
+// WithSource sets the name of source document, which is used in Metadata and PosError
+func WithSource(name string) Option {
+	return func(o *options) {
+		o.source = name
+	}
+}
+
+// IsSet returns true if the value at path is present in the YAML document, e.g. md.IsSet("servers[1].port")
+func (md *Metadata) IsSet(path string) bool {
+	_, ok := md.fields[path]
+	return ok
+}
+
+// Get returns the provenance of the value at path, false if it is not present in the YAML document
+func (md *Metadata) Get(path string) (FieldMeta, bool) {
+	fm, ok := md.fields[path]
+	return fm, ok
+}
+
+// Paths returns the paths of all values present in the YAML document, in document order
+func (md *Metadata) Paths() []string {
+	return append([]string(nil), md.paths...)
+}
+
+// record records all values in YAML document doc
+func (md *Metadata) record(doc *yaml.Node, o *options) {
+	md.fields = make(map[string]FieldMeta)
+	md.paths = nil
+	src := o.source
+	if s, ok := o.sources[doc]; ok {
+		src = s
+	}
+	for _, c := range doc.Content {
+		md.walk(c, "", src, o)
+	}
+}
+
+func (md *Metadata) walk(n *yaml.Node, path, src string, o *options) {
+	if s, ok := o.sources[n]; ok {
+		src = s
+	}
+	if n.Kind == yaml.AliasNode {
+		md.walk(n.Alias, path, src, o)
+		return
+	}
+	if path != "" {
+		if _, exists := md.fields[path]; !exists {
+			md.paths = append(md.paths, path)
+		}
+		md.fields[path] = FieldMeta{Path: path, Source: src, Line: n.Line, Column: n.Column}
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for _, pair := range mappingPairs(n) {
+			md.walk(pair[1], appendKey(path, pair[0].Value), src, o)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			md.walk(c, appendIndex(path, i), src, o)
+		}
+	}
+}
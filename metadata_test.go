@@ -0,0 +1,86 @@
+package extyaml_test
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type metadataServer struct {
+	Name   string
+	Port   int
+	Subnet net.IPNet
+}
+
+type metadataConfig struct {
+	Interval int
+	Servers  []metadataServer
+	Labels   map[string]string
+}
+
+func TestMetadata(t *testing.T) {
+	doc := `interval: 0
+servers:
+  - name: s1
+  - name: s2
+    port: 8080
+labels:
+  a.b: x
+`
+	md := new(extyaml.Metadata)
+	cfg := new(metadataConfig)
+	err := extyaml.UnmarshalExt([]byte(doc), cfg, extyaml.WithMetadata(md), extyaml.WithSource("main.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"interval", "servers", "servers[1]", "servers[1].port", "labels", `labels["a.b"]`} {
+		if !md.IsSet(p) {
+			t.Fatalf("expect %v is set", p)
+		}
+	}
+	for _, p := range []string{"servers[0].port", "servers[2]", "servers[1].subnet"} {
+		if md.IsSet(p) {
+			t.Fatalf("expect %v is not set", p)
+		}
+	}
+	fm, ok := md.Get("servers[1].port")
+	if !ok || fm.Line != 5 || fm.Column != 11 || fm.Source != "main.yaml" {
+		t.Fatalf("unexpected metadata %+v", fm)
+	}
+	if len(md.Paths()) != 9 {
+		t.Fatalf("unexpected paths %v", md.Paths())
+	}
+
+	//source name in error
+	err = extyaml.UnmarshalExt([]byte("servers:\n  - subnet: 10.0.0.0/33\n"), cfg, extyaml.WithSource("bad.yaml"))
+	var perr *extyaml.PosError
+	if !errors.As(err, &perr) || perr.Source != "bad.yaml" || perr.Line != 2 {
+		t.Fatalf("expect error in bad.yaml line 2, got %v", err)
+	}
+
+	//source of included files
+	fsys := fstest.MapFS{
+		"main.yaml":      {Data: []byte("interval: 1\nservers: !include-glob servers/*.yaml\n")},
+		"servers/a.yaml": {Data: []byte("- name: a\n")},
+		"servers/b.yaml": {Data: []byte("- name: b\n  port: 1\n")},
+	}
+	err = extyaml.UnmarshalExtFS(fsys, "main.yaml", cfg, extyaml.WithMetadata(md))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fm, _ := md.Get("interval"); fm.Source != "main.yaml" || fm.Line != 1 {
+		t.Fatalf("unexpected metadata %+v", fm)
+	}
+	if fm, _ := md.Get("servers[0].name"); fm.Source != "servers/a.yaml" || fm.Line != 1 {
+		t.Fatalf("unexpected metadata %+v", fm)
+	}
+	if fm, _ := md.Get("servers[1].port"); fm.Source != "servers/b.yaml" || fm.Line != 2 {
+		t.Fatalf("unexpected metadata %+v", fm)
+	}
+	if md.IsSet("labels") {
+		t.Fatal("expect records of previous unmarshalling are cleared")
+	}
+}
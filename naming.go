@@ -0,0 +1,144 @@
+package extyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// NamingStrategy converts a Go struct field name into YAML key
+type NamingStrategy func(fieldName string) string
+
+// splitWords splits a Go identifier into words, e.g. "HTTPServerAddr" into "HTTP", "Server", "Addr"
+func splitWords(s string) []string {
+	runes := []rune(s)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		if !unicode.IsUpper(cur) {
+			continue
+		}
+		nextIsLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+// LowerCase is the default NamingStrategy same as gopkg.in/yaml.v3, e.g. "TimeScalar" into "timescalar"
+func LowerCase(fieldName string) string {
+	return strings.ToLower(fieldName)
+}
+
+// SnakeCase is the NamingStrategy converts "TimeScalar" into "time_scalar"
+func SnakeCase(fieldName string) string {
+	return strings.ToLower(strings.Join(splitWords(fieldName), "_"))
+}
+
+// KebabCase is the NamingStrategy converts "TimeScalar" into "time-scalar"
+func KebabCase(fieldName string) string {
+	return strings.ToLower(strings.Join(splitWords(fieldName), "-"))
+}
+
+// CamelCase is the NamingStrategy converts "TimeScalar" into "timeScalar"
+func CamelCase(fieldName string) string {
+	words := splitWords(fieldName)
+	for i, w := range words {
+		w = strings.ToLower(w)
+		if i > 0 {
+			runes := []rune(w)
+			runes[0] = unicode.ToUpper(runes[0])
+			w = string(runes)
+		}
+		words[i] = w
+	}
+	return strings.Join(words, "")
+}
+
+// SetNamingStrategy sets the NamingStrategy for fields without a name in yaml tag, default is LowerCase
+func (reg *Registry) SetNamingStrategy(fn NamingStrategy) {
+	reg.naming = fn
+}
+
+// SetJSONTagFallback sets whether to use the json tag for fields without yaml tag
+func (reg *Registry) SetJSONTagFallback(enable bool) {
+	reg.jsonTagFallback = enable
+}
+
+// SetCaseInsensitive sets whether to match YAML keys with struct fields case-insensitively on unmarshalling
+func (reg *Registry) SetCaseInsensitive(enable bool) {
+	reg.caseInsensitive = enable
+}
+
+// yamlTag returns the YAML key name and flags of field according to the naming settings of reg,
+// name is "-" if field should be skipped
+func (reg *Registry) yamlTag(field reflect.StructField) (name, flags string) {
+	tag, exists := field.Tag.Lookup("yaml")
+	if !exists && reg.jsonTagFallback {
+		var jsonFlags string
+		name, jsonFlags, _ = strings.Cut(field.Tag.Get("json"), ",")
+		//only omitempty is applicable among json tag options
+		for _, f := range strings.Split(jsonFlags, ",") {
+			if f == "omitempty" {
+				flags = f
+			}
+		}
+	} else {
+		name, flags, _ = strings.Cut(tag, ",")
+	}
+	if name == "" {
+		if reg.naming != nil {
+			name = reg.naming(field.Name)
+		} else {
+			name = LowerCase(field.Name)
+		}
+	}
+	return name, flags
+}
+
+// mirrorTag returns the tag of field in the mirror struct that has the YAML key name as the result of yamlTag()
+func (reg *Registry) mirrorTag(field reflect.StructField) reflect.StructTag {
+	name, flags := reg.yamlTag(field)
+	if isRemainField(field) {
+		name, flags = remainKey, "omitempty"
+	}
+	v := name
+	if flags != "" {
+		v += "," + flags
+	}
+	//reflect.StructTag.Get returns the first value of the key
+	return reflect.StructTag(fmt.Sprintf("yaml:%q %s", v, field.Tag))
+}
+
+// foldKeys changes mapping keys under n that case-insensitively match a struct field into the field's YAML key,
+// t is the Go type n maps to; no-op if case-insensitive matching is not enabled
+func (reg *Registry) foldKeys(n *yaml.Node, t reflect.Type) error {
+	if !reg.caseInsensitive {
+		return nil
+	}
+	return walkNode(n, t, func(n *yaml.Node, t reflect.Type) error {
+		if n.Kind != yaml.MappingNode || t == nil || t.Kind() != reflect.Struct {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if _, ok := yamlFieldByKey(t, k.Value); ok {
+				continue
+			}
+			for _, f := range fields {
+				if strings.EqualFold(f.key, k.Value) && keyIndex(n, f.key) < 0 {
+					k.Value = f.key
+					break
+				}
+			}
+		}
+		return nil
+	})
+}
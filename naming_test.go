@@ -0,0 +1,89 @@
+package extyaml_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+func TestNamingStrategy(t *testing.T) {
+	caseList := []struct {
+		fn       extyaml.NamingStrategy
+		input    string
+		expected string
+	}{
+		{extyaml.LowerCase, "TimeScalar", "timescalar"},
+		{extyaml.SnakeCase, "TimeScalar", "time_scalar"},
+		{extyaml.SnakeCase, "HTTPServerAddr", "http_server_addr"},
+		{extyaml.SnakeCase, "UserID", "user_id"},
+		{extyaml.SnakeCase, "Port2Name", "port2_name"},
+		{extyaml.KebabCase, "TimeScalar", "time-scalar"},
+		{extyaml.CamelCase, "TimeScalar", "timeScalar"},
+		{extyaml.CamelCase, "HTTPServer", "httpServer"},
+	}
+	for i, c := range caseList {
+		if r := c.fn(c.input); r != c.expected {
+			t.Fatalf("case %d failed, expect %v got %v", i, c.expected, r)
+		}
+	}
+}
+
+type namingSub struct {
+	SubnetAddr net.IPNet
+}
+
+type namingConfig struct {
+	ServerName string
+	ListenPort int    `yaml:"port"`
+	JSONOnly   string `json:"json_only,omitempty"`
+	Ignored    string `json:"-"`
+	SubConfig  namingSub
+}
+
+func TestNamingRegistry(t *testing.T) {
+	extyaml.RegisteredTypes.SetNamingStrategy(extyaml.SnakeCase)
+	extyaml.RegisteredTypes.SetJSONTagFallback(true)
+	defer extyaml.RegisteredTypes.SetNamingStrategy(nil)
+	defer extyaml.RegisteredTypes.SetJSONTagFallback(false)
+	_, subnet, _ := net.ParseCIDR("10.0.0.0/8")
+	in := namingConfig{
+		ServerName: "s1",
+		ListenPort: 80,
+		JSONOnly:   "j",
+		Ignored:    "ignored",
+		SubConfig:  namingSub{SubnetAddr: *subnet},
+	}
+	buf, err := extyaml.MarshalExt(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `server_name: s1
+port: 80
+json_only: j
+sub_config:
+    subnet_addr: 10.0.0.0/8
+`
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+	out := new(namingConfig)
+	err = extyaml.UnmarshalExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ServerName != "s1" || out.ListenPort != 80 || out.JSONOnly != "j" || out.Ignored != "" || out.SubConfig.SubnetAddr.String() != "10.0.0.0/8" {
+		t.Fatalf("unexpected unmarshal result %+v", out)
+	}
+
+	extyaml.RegisteredTypes.SetCaseInsensitive(true)
+	defer extyaml.RegisteredTypes.SetCaseInsensitive(false)
+	out = new(namingConfig)
+	err = extyaml.UnmarshalExt([]byte("Server_Name: s2\nPORT: 8080\nSub_Config:\n  SUBNET_ADDR: 192.168.0.0/16\n"), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.ServerName != "s2" || out.ListenPort != 8080 || out.SubConfig.SubnetAddr.String() != "192.168.0.0/16" {
+		t.Fatalf("unexpected case-insensitive unmarshal result %+v", out)
+	}
+}
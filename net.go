@@ -8,8 +8,8 @@ import (
 )
 
 func init() {
-	RegisterExt[net.HardwareAddr](macTtoStr, macFromStr)
-	RegisterExt[net.IPNet](ipnetTtoStr, ipnetFromStr)
+	RegisterExt[net.HardwareAddr](macTtoStr, macFromStr, WithTag("!mac"))
+	RegisterExt[net.IPNet](ipnetTtoStr, ipnetFromStr, WithTag("!cidr"))
 }
 
 // support formats: xx:xx:xx:xx:xx:xx, xx-xx-xx-xx-xx-xx
@@ -48,11 +48,23 @@ func macTtoStr(in any) (string, error) {
 }
 
 func ipnetFromStr(text string) (any, error) {
+	if text == "" {
+		//zero value, the same as macFromStr
+		return net.IPNet{}, nil
+	}
 	_, r, err := net.ParseCIDR(text)
-	return *r, err
+	if err != nil {
+		return nil, err
+	}
+	return *r, nil
 }
 
 func ipnetTtoStr(in any) (string, error) {
 	v := in.(net.IPNet)
+	if v.IP == nil {
+		//zero value, net.IPNet.String() returns "<nil>" which can't be parsed back,
+		//use "" like the zero value of net.HardwareAddr so that it round trips
+		return "", nil
+	}
 	return v.String(), nil
 }
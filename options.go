@@ -0,0 +1,34 @@
+package extyaml
+
+import "gopkg.in/yaml.v3"
+
+// Option customizes a single marshaling/unmarshalling call
+type Option func(*options)
+
+type options struct {
+	maxIncludeDepth int
+	onWarning       func(w Warning)
+	dynamicTags     bool
+	emitTags        bool
+	onlyPresent     bool
+	unredacted      bool
+	canonical       bool
+	resetNil        bool
+	//merging is set by MergeExt and UnmarshalExtDefault, a sequence replaces the existing slice and ResetTag is honored
+	merging  bool
+	metadata *Metadata
+	//source is the name of source document
+	source string
+	//sources is the name of source document of some nodes, set by UnmarshalExtFS
+	sources map[*yaml.Node]string
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{
+		maxIncludeDepth: DefaultMaxIncludeDepth,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
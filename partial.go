@@ -0,0 +1,159 @@
+package extyaml
+
+import (
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// OnlyPresentKeys makes unmarshalling only touch the Go values of the keys present in the YAML document:
+// absent keys leave the original values untouched, including nil pointers, nil maps and existing slices;
+// present slices are replaced, present maps are merged, explicit null sets pointer, map, slice and interface{} to nil.
+func OnlyPresentKeys() Option {
+	return func(o *options) {
+		o.onlyPresent = true
+	}
+}
+
+// mappingPairs returns the key/value pairs of mapping node n, with merge keys expanded
+func mappingPairs(n *yaml.Node) [][2]*yaml.Node {
+	var pairs, merged [][2]*yaml.Node
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		k, v := n.Content[i], n.Content[i+1]
+		if k.ShortTag() != "!!merge" {
+			pairs = append(pairs, [2]*yaml.Node{k, v})
+			continue
+		}
+		if v.Kind == yaml.AliasNode {
+			v = v.Alias
+		}
+		srcs := []*yaml.Node{v}
+		if v.Kind == yaml.SequenceNode {
+			srcs = v.Content
+		}
+		for _, src := range srcs {
+			if src.Kind == yaml.AliasNode {
+				src = src.Alias
+			}
+			if src.Kind == yaml.MappingNode {
+				merged = append(merged, mappingPairs(src)...)
+			}
+		}
+	}
+	//explicit keys take precedence over merged ones
+	return append(merged, pairs...)
+}
+
+// fieldByIndex returns the nested field of struct v by index, nil pointers along the way are allocated if alloc is true,
+// otherwise an invalid value is returned
+func fieldByIndex(v reflect.Value, index []int, alloc bool) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
+
+// inlineMapField returns the index of the inline map field of struct type t, nil if there is none
+func inlineMapField(t reflect.Type) []int {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Type.Kind() != reflect.Map {
+			continue
+		}
+		if _, flags := RegisteredTypes.yamlTag(field); strings.Contains(flags, "inline") {
+			return []int{i}
+		}
+	}
+	return nil
+}
+
+// applyPresent sets out to ext for the parts present in n, ext is the converted value freshly decoded from n
+func applyPresent(n *yaml.Node, ext, out reflect.Value, o *options) {
+	switch n.Kind {
+	case yaml.DocumentNode:
+		if len(n.Content) == 0 {
+			return
+		}
+		n = n.Content[0]
+	case yaml.AliasNode:
+		n = n.Alias
+	}
+	if n.ShortTag() == "!!null" {
+		switch out.Kind() {
+		case reflect.Pointer, reflect.Map, reflect.Slice, reflect.Interface:
+			if out.CanSet() {
+				out.Set(reflect.Zero(out.Type()))
+			}
+		}
+		return
+	}
+	t := out.Type()
+	if ext.Type() != dynamicExtPtrType && t.Kind() == reflect.Pointer && ext.Kind() == reflect.Pointer && !ext.IsNil() {
+		if out.IsNil() {
+			out.Set(reflect.New(t.Elem()))
+		}
+		applyPresent(n, ext.Elem(), out.Elem(), o)
+		return
+	}
+	if ext.Type() != recursiveExtType && !isCodecType(t) {
+		switch {
+		case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+			for _, pair := range mappingPairs(n) {
+				if index := remainField(t); index != nil && pair[0].Value == remainKey {
+					translateStructInline(ext.FieldByIndex(index).Interface(), out.FieldByIndex(index).Addr().Interface(), "", false, o)
+					continue
+				}
+				f, ok := yamlFieldByKey(t, pair[0].Value)
+				if !ok {
+					continue
+				}
+				extF := fieldByIndex(ext, f.index, false)
+				outF := fieldByIndex(out, f.index, true)
+				if !extF.IsValid() || !outF.IsValid() {
+					continue
+				}
+				applyPresent(pair[1], extF, outF, o)
+			}
+			if index := inlineMapField(t); index != nil {
+				applyMap(ext.FieldByIndex(index), out.FieldByIndex(index), o)
+			}
+			dropKnownKeys(out, t)
+			return
+		case t.Kind() == reflect.Map && n.Kind == yaml.MappingNode:
+			applyMap(ext, out, o)
+			return
+		case t.Kind() == reflect.Slice:
+			//a present slice replaces the existing one
+			out.Set(reflect.MakeSlice(t, 0, ext.Len()))
+		}
+	}
+	translateStructInline(ext.Interface(), out.Addr().Interface(), "", false, o)
+}
+
+// applyMap sets all entries of ext map into out map
+func applyMap(ext, out reflect.Value, o *options) {
+	if ext.Len() == 0 {
+		return
+	}
+	if out.IsNil() {
+		out.Set(reflect.MakeMap(out.Type()))
+	}
+	iter := ext.MapRange()
+	for iter.Next() {
+		newkey := reflect.New(out.Type().Key())
+		newval := reflect.New(out.Type().Elem())
+		translateStructInline(iter.Key().Interface(), newkey.Interface(), "", false, o)
+		translateStructInline(iter.Value().Interface(), newval.Interface(), "", false, o)
+		out.SetMapIndex(newkey.Elem(), newval.Elem())
+	}
+}
@@ -0,0 +1,106 @@
+package extyaml_test
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type partialSub struct {
+	Subnet net.IPNet
+	Port   int
+}
+
+type partialInline struct {
+	Zone string
+}
+
+type partialConfig struct {
+	Name    string
+	Subnet  *net.IPNet
+	Sub     *partialSub
+	Subs    []partialSub
+	Ports   []int
+	Labels  map[string]string
+	Macs    map[string]net.HardwareAddr
+	Extra   any
+	Inline  partialInline `yaml:",inline"`
+	Self    *partialConfig
+	Unknown map[string]any `yaml:",inline"`
+}
+
+func TestOnlyPresentKeys(t *testing.T) {
+	subnet := mustCIDR("10.0.0.0/8")
+	orig := partialConfig{
+		Name:   "n",
+		Subnet: &subnet,
+		Subs:   []partialSub{{Port: 1}, {Port: 2}, {Port: 3}},
+		Ports:  []int{1, 2, 3},
+		Labels: map[string]string{"a": "1"},
+		Extra:  1,
+		Self:   &partialConfig{Name: "self", Ports: []int{9}},
+	}
+	out := orig
+	doc := `
+base: &base
+  zone: z1
+sub:
+  port: 80
+ports: [5]
+labels:
+  b: "2"
+macs:
+  m1: 00:11:22:33:44:55
+self:
+  name: self2
+<<: *base
+unknownkey: 1
+`
+	err := extyaml.UnmarshalExt([]byte(doc), &out, extyaml.OnlyPresentKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Name != "n" || out.Subnet != orig.Subnet || out.Extra != 1 || !reflect.DeepEqual(out.Subs, orig.Subs) {
+		t.Fatalf("absent keys are changed: %+v", out)
+	}
+	if out.Sub == nil || out.Sub.Port != 80 || !reflect.DeepEqual(out.Ports, []int{5}) {
+		t.Fatalf("unexpected present keys: %+v", out)
+	}
+	if !reflect.DeepEqual(out.Labels, map[string]string{"a": "1", "b": "2"}) || out.Macs["m1"].String() != "00:11:22:33:44:55" {
+		t.Fatalf("unexpected maps: %+v", out)
+	}
+	if out.Inline.Zone != "z1" || out.Unknown["unknownkey"] != 1 {
+		t.Fatalf("unexpected inline: %+v", out)
+	}
+	if out.Self.Name != "self2" || !reflect.DeepEqual(out.Self.Ports, []int{9}) {
+		t.Fatalf("unexpected recursive field: %+v", out.Self)
+	}
+
+	//nil pointers and maps stay nil
+	out = partialConfig{}
+	err = extyaml.UnmarshalExt([]byte("name: x\n"), &out, extyaml.OnlyPresentKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, partialConfig{Name: "x"}) {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	//explicit null
+	out = orig
+	err = extyaml.UnmarshalExt([]byte("subnet: null\nports: null\nlabels: ~\nextra:\nname: null\n"), &out, extyaml.OnlyPresentKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Subnet != nil || out.Ports != nil || out.Labels != nil || out.Extra != nil || out.Name != "n" {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	//error
+	err = extyaml.UnmarshalExt([]byte("sub:\n  subnet: 10.0.0.0/33\n"), &out, extyaml.OnlyPresentKeys())
+	if err == nil {
+		t.Fatal("expect error")
+	}
+}
@@ -0,0 +1,461 @@
+package extyaml
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// PatchKind is the kind of patch document used by PatchExt
+type PatchKind int
+
+const (
+	//MergePatch is RFC 7386 JSON merge patch, expressed in YAML
+	MergePatch PatchKind = iota
+	//JSONPatch is RFC 6902 JSON Patch, expressed in YAML
+	JSONPatch
+)
+
+// PatchExt applies patch to the value target points to, patch values are parsed via registered codecs,
+// e.g. a patch value "10.0.0.0/8" of a net.IPNet field; non-export fields and fields with SkipTag of target are kept,
+// so are struct fields not changed by patch, elements of changed slices and maps are replaced.
+// For JSONPatch, paths are JSON pointers, e.g. "/servers/0/subnet"; "test" operations compare values via registered codecs,
+// the value of a failed test is in the error, values of secret and encrypted fields are SecretMask unless Unredacted option is specified.
+func PatchExt(target any, patch []byte, kind PatchKind, opts ...Option) error {
+	tv := reflect.ValueOf(target)
+	if tv.Kind() != reflect.Pointer || tv.IsNil() {
+		return fmt.Errorf("the object to patch is not a pointer")
+	}
+	t := tv.Type().Elem()
+	n, err := marshalNode(target, newOptions(nil))
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	err = yaml.Unmarshal(patch, &doc)
+	if err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	switch kind {
+	case MergePatch:
+		err = RegisteredTypes.foldKeys(&doc, t)
+		if err != nil {
+			return err
+		}
+		err = applyAliases(&doc, t, newOptions(nil))
+		if err != nil {
+			return err
+		}
+		n, err = mergePatch(n, doc.Content[0], t, "")
+	case JSONPatch:
+		n, err = jsonPatch(n, doc.Content[0], t, newOptions(opts))
+	default:
+		return fmt.Errorf("unknown patch kind %d", kind)
+	}
+	if err != nil {
+		return err
+	}
+	r := reflect.New(t)
+	err = unmarshalNode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{n}}, r.Interface(), newOptions(nil))
+	if err != nil {
+		return err
+	}
+	result := reflect.New(t)
+	result.Elem().Set(deepCopy(tv.Elem()))
+	err = applyPatched(result.Elem(), r.Elem())
+	if err != nil {
+		return err
+	}
+	tv.Elem().Set(result.Elem())
+	return postUnmarshal(target)
+}
+
+// applyPatched sets the fields of struct dst that are marshaled to the patched value src,
+// non-export fields and fields with SkipTag are kept; a field is also kept if its marshaled value is not changed,
+// so that the parts dropped by its codec are kept, e.g. the location of a time.Time
+func applyPatched(dst, src reflect.Value) error {
+	if dst.Kind() == reflect.Pointer && !dst.IsNil() && !src.IsNil() {
+		return applyPatched(dst.Elem(), src.Elem())
+	}
+	if dst.Kind() == reflect.Struct && !isCodecType(dst.Type()) {
+		for i := 0; i < dst.NumField(); i++ {
+			field := dst.Type().Field(i)
+			if _, skip := field.Tag.Lookup(SkipTag); skip {
+				continue
+			}
+			if !field.IsExported() && !isUnexportedEmbedded(field) {
+				continue
+			}
+			err := applyPatched(dst.Field(i), src.Field(i))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !dst.CanSet() {
+		return nil
+	}
+	if (dst.Kind() == reflect.Pointer || dst.Kind() == reflect.Interface) && (dst.IsNil() || src.IsNil()) {
+		if !dst.IsNil() || !src.IsNil() {
+			dst.Set(src)
+		}
+		return nil
+	}
+	dbuf, err := marshalPlain(dst.Interface())
+	if err != nil {
+		return err
+	}
+	sbuf, err := marshalPlain(src.Interface())
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(dbuf, sbuf) {
+		dst.Set(src)
+	}
+	return nil
+}
+
+// cloneNode returns a deep copy of n
+func cloneNode(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = cloneNode(child)
+	}
+	return &c
+}
+
+// childType returns the Go type of the value of key in a value of type t, nil if unknown
+func childType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+	t = indirectType(t)
+	if isCodecType(t) {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if f, ok := yamlFieldByKey(t, key); ok {
+			return f.field.Type
+		}
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return t.Elem()
+	}
+	return nil
+}
+
+// decodeAs decodes a copy of n into a new value of type t (pointers are de-referenced), nil if t is nil
+func decodeAs(n *yaml.Node, t reflect.Type) (reflect.Value, error) {
+	if t == nil {
+		return reflect.Value{}, nil
+	}
+	v := reflect.New(indirectType(t))
+	err := unmarshalNode(&yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{cloneNode(n)}}, v.Interface(), newOptions(nil))
+	return v, err
+}
+
+// mergePatch applies RFC 7386 merge patch p to target and returns the result, t is the Go type of target
+func mergePatch(target, p *yaml.Node, t reflect.Type, path string) (*yaml.Node, error) {
+	if p.Kind != yaml.MappingNode {
+		if _, err := decodeAs(p, t); err != nil {
+			return nil, fmt.Errorf("%v: %w", pathName(path), err)
+		}
+		return p, nil
+	}
+	if target == nil || target.Kind != yaml.MappingNode {
+		target = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	for i := 0; i+1 < len(p.Content); i += 2 {
+		k, v := p.Content[i], p.Content[i+1]
+		ti := keyIndex(target, k.Value)
+		if v.ShortTag() == "!!null" {
+			if ti >= 0 {
+				target.Content = append(target.Content[:ti], target.Content[ti+2:]...)
+			}
+			continue
+		}
+		var tv *yaml.Node
+		if ti >= 0 {
+			tv = target.Content[ti+1]
+		}
+		r, err := mergePatch(tv, v, childType(t, k.Value), appendKey(path, k.Value))
+		if err != nil {
+			return nil, err
+		}
+		if ti >= 0 {
+			target.Content[ti+1] = r
+		} else {
+			target.Content = append(target.Content, k, r)
+		}
+	}
+	return target, nil
+}
+
+func pathName(path string) string {
+	if path == "" {
+		return "<root>"
+	}
+	return path
+}
+
+// parsePointer parses JSON pointer s into reference tokens
+func parsePointer(s string) ([]string, error) {
+	if s == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(s, "/") {
+		return nil, fmt.Errorf("invalid JSON pointer %q", s)
+	}
+	tokens := strings.Split(s[1:], "/")
+	for i, tk := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(tk, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// seqIndex returns the index of token in sequence n, max is the max allowed index
+func seqIndex(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 || i > max || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid index %v", token)
+	}
+	return i, nil
+}
+
+// pointerGet returns the node referenced by tokens under root, and its Go type
+func pointerGet(root *yaml.Node, t reflect.Type, tokens []string) (*yaml.Node, reflect.Type, error) {
+	n := root
+	for _, tk := range tokens {
+		switch n.Kind {
+		case yaml.MappingNode:
+			v := mappingValue(n, tk)
+			if v == nil {
+				return nil, nil, fmt.Errorf("key %v not found", tk)
+			}
+			n = v
+		case yaml.SequenceNode:
+			i, err := seqIndex(tk, len(n.Content)-1)
+			if err != nil {
+				return nil, nil, err
+			}
+			n = n.Content[i]
+		default:
+			return nil, nil, fmt.Errorf("%v is not a container", tk)
+		}
+		t = childType(t, tk)
+	}
+	return n, t, nil
+}
+
+// pointerAdd adds value at tokens under root, returns the new root
+func pointerAdd(root *yaml.Node, t reflect.Type, tokens []string, value *yaml.Node) (*yaml.Node, error) {
+	if len(tokens) == 0 {
+		if _, err := decodeAs(value, t); err != nil {
+			return nil, err
+		}
+		return value, nil
+	}
+	parent, pt, err := pointerGet(root, t, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	if _, err := decodeAs(value, childType(pt, last)); err != nil {
+		return nil, err
+	}
+	switch parent.Kind {
+	case yaml.MappingNode:
+		if i := keyIndex(parent, last); i >= 0 {
+			parent.Content[i+1] = value
+		} else {
+			parent.Content = append(parent.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: last}, value)
+		}
+	case yaml.SequenceNode:
+		i := len(parent.Content)
+		if last != "-" {
+			i, err = seqIndex(last, len(parent.Content))
+			if err != nil {
+				return nil, err
+			}
+		}
+		parent.Content = append(parent.Content[:i], append([]*yaml.Node{value}, parent.Content[i:]...)...)
+	default:
+		return nil, fmt.Errorf("parent is not a container")
+	}
+	return root, nil
+}
+
+// pointerRemove removes the value at tokens under root and returns it
+func pointerRemove(root *yaml.Node, tokens []string) (*yaml.Node, error) {
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("can't remove root")
+	}
+	parent, _, err := pointerGet(root, nil, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch parent.Kind {
+	case yaml.MappingNode:
+		i := keyIndex(parent, last)
+		if i < 0 {
+			return nil, fmt.Errorf("key %v not found", last)
+		}
+		v := parent.Content[i+1]
+		parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+		return v, nil
+	case yaml.SequenceNode:
+		i, err := seqIndex(last, len(parent.Content)-1)
+		if err != nil {
+			return nil, err
+		}
+		v := parent.Content[i]
+		parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+		return v, nil
+	}
+	return nil, fmt.Errorf("parent is not a container")
+}
+
+// codecEqual returns true if a and b are same value of Go type t, compared via registered codecs
+func codecEqual(a, b *yaml.Node, t reflect.Type) (bool, error) {
+	if t == nil {
+		return nodeEqual(a, b), nil
+	}
+	av, err := decodeAs(a, t)
+	if err != nil {
+		return false, err
+	}
+	bv, err := decodeAs(b, t)
+	if err != nil {
+		return false, err
+	}
+	an, err := marshalNode(av.Interface(), newOptions(nil))
+	if err != nil {
+		return false, err
+	}
+	bn, err := marshalNode(bv.Interface(), newOptions(nil))
+	if err != nil {
+		return false, err
+	}
+	return nodeEqual(an, bn), nil
+}
+
+// jsonPatch applies RFC 6902 JSON patch p to root and returns the result, t is the Go type of root
+func jsonPatch(root, p *yaml.Node, t reflect.Type, o *options) (*yaml.Node, error) {
+	if p.Kind != yaml.SequenceNode {
+		return nil, newPosError(p, fmt.Errorf("JSON patch must be a sequence of operations"))
+	}
+	for _, opn := range p.Content {
+		if opn.Kind != yaml.MappingNode {
+			return nil, newPosError(opn, fmt.Errorf("operation must be a mapping"))
+		}
+		var op, path, from string
+		if v := mappingValue(opn, "op"); v != nil {
+			op = v.Value
+		}
+		if v := mappingValue(opn, "path"); v != nil {
+			path = v.Value
+		} else {
+			return nil, newPosError(opn, fmt.Errorf("%v: missing path", op))
+		}
+		if v := mappingValue(opn, "from"); v != nil {
+			from = v.Value
+		}
+		value := mappingValue(opn, "value")
+		var err error
+		root, err = applyOperation(root, t, op, path, from, value, o)
+		if err != nil {
+			return nil, newPosError(opn, fmt.Errorf("%v %v: %w", op, path, err))
+		}
+	}
+	return root, nil
+}
+
+// applyOperation applies a JSON patch operation to root and returns the result
+func applyOperation(root *yaml.Node, t reflect.Type, op, path, from string, value *yaml.Node, o *options) (*yaml.Node, error) {
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return nil, err
+	}
+	switch op {
+	case "add", "replace", "test":
+		if value == nil {
+			return nil, fmt.Errorf("missing value")
+		}
+	case "move", "copy":
+		fromTokens, err := parsePointer(from)
+		if err != nil {
+			return nil, err
+		}
+		if op == "move" {
+			if strings.HasPrefix(path+"/", from+"/") && path != from {
+				return nil, fmt.Errorf("can't move %v into its child", from)
+			}
+			value, err = pointerRemove(root, fromTokens)
+		} else {
+			value, _, err = pointerGet(root, t, fromTokens)
+			if value != nil {
+				value = cloneNode(value)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("from %v: %w", from, err)
+		}
+		return pointerAdd(root, t, tokens, value)
+	}
+	switch op {
+	case "add":
+		return pointerAdd(root, t, tokens, value)
+	case "remove":
+		_, err = pointerRemove(root, tokens)
+		return root, err
+	case "replace":
+		if _, _, err := pointerGet(root, t, tokens); err != nil {
+			return nil, err
+		}
+		if len(tokens) > 0 {
+			parent, _, _ := pointerGet(root, t, tokens[:len(tokens)-1])
+			if parent.Kind == yaml.SequenceNode {
+				//adding to sequence inserts a new element
+				if _, err := pointerRemove(root, tokens); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return pointerAdd(root, t, tokens, value)
+	case "test":
+		cur, ct, err := pointerGet(root, t, tokens)
+		if err != nil {
+			return nil, err
+		}
+		eq, err := codecEqual(cur, value, ct)
+		if err != nil {
+			return nil, err
+		}
+		if !eq {
+			if !o.unredacted {
+				//root is not redacted or encrypted, mask the values of secret and encrypted fields
+				secrets := map[*yaml.Node]bool{}
+				for _, opt := range []string{SecretOpt, EncryptedOpt} {
+					fieldValues(root, t, opt, func(v *yaml.Node) error {
+						markSecret(v, secrets)
+						return nil
+					})
+				}
+				cur = maskedCopy(cur, secrets)
+			}
+			return nil, fmt.Errorf("test failed, value is %v", renderNode(cur))
+		}
+		return root, nil
+	}
+	return nil, fmt.Errorf("unknown operation %q", op)
+}
@@ -0,0 +1,162 @@
+package extyaml_test
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type patchServer struct {
+	Name   string
+	Subnet net.IPNet
+}
+
+type patchConfig struct {
+	Name    string
+	Servers []patchServer
+	Labels  map[string]string
+	Sub     *patchServer
+}
+
+func newPatchConfig() *patchConfig {
+	return &patchConfig{
+		Name: "n",
+		Servers: []patchServer{
+			{Name: "s1", Subnet: mustCIDR("10.0.0.0/24")},
+			{Name: "s2", Subnet: mustCIDR("10.0.2.0/24")},
+		},
+		Labels: map[string]string{"a": "1", "b": "2"},
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	cfg := newPatchConfig()
+	err := extyaml.PatchExt(cfg, []byte(`
+labels:
+  a: null
+  c: "3"
+sub:
+  subnet: 10.0.0.0/8
+servers:
+  - name: s3
+    subnet: 10.3.0.0/16
+`), extyaml.MergePatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "n" || len(cfg.Labels) != 2 || cfg.Labels["c"] != "3" || cfg.Labels["b"] != "2" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	if cfg.Sub == nil || cfg.Sub.Subnet.String() != "10.0.0.0/8" || len(cfg.Servers) != 1 || cfg.Servers[0].Subnet.String() != "10.3.0.0/16" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	err = extyaml.PatchExt(cfg, []byte("sub:\n  subnet: 10.0.0.0/33\n"), extyaml.MergePatch)
+	if err == nil || !strings.Contains(err.Error(), "sub.subnet") {
+		t.Fatalf("expect error naming sub.subnet, got %v", err)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	cfg := newPatchConfig()
+	err := extyaml.PatchExt(cfg, []byte(`
+- op: test
+  path: /servers/0/subnet
+  value: 10.0.0.1/24
+- op: replace
+  path: /servers/0/subnet
+  value: 10.0.1.0/24
+- op: add
+  path: /servers/-
+  value: {name: s3, subnet: 10.0.3.0/24}
+- op: remove
+  path: /labels/a
+- op: copy
+  from: /servers/1
+  path: /sub
+- op: move
+  from: /labels/b
+  path: /labels/x~1y
+`), extyaml.JSONPatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Servers) != 3 || cfg.Servers[0].Subnet.String() != "10.0.1.0/24" || cfg.Servers[2].Name != "s3" {
+		t.Fatalf("unexpected servers %+v", cfg.Servers)
+	}
+	if len(cfg.Labels) != 1 || cfg.Labels["x/y"] != "2" || cfg.Sub == nil || cfg.Sub.Name != "s2" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+
+	for _, c := range []struct {
+		patch, errStr string
+	}{
+		{"- {op: test, path: /servers/0/subnet, value: 10.9.0.0/24}", "test /servers/0/subnet: test failed"},
+		{"- {op: add, path: /servers/0/subnet, value: 10.9.0.0/33}", "add /servers/0/subnet"},
+		{"- {op: remove, path: /labels/none}", "remove /labels/none: key none not found"},
+		{"- {op: replace, path: /servers/9, value: {}}", "replace /servers/9: invalid index 9"},
+		{"- {op: copy, from: /none, path: /name}", "copy /name: from /none"},
+		{"- {op: bad, path: /name}", "unknown operation"},
+	} {
+		err = extyaml.PatchExt(cfg, []byte(c.patch), extyaml.JSONPatch)
+		var perr *extyaml.PosError
+		if !errors.As(err, &perr) || !strings.Contains(err.Error(), c.errStr) {
+			t.Fatalf("patch %v expect error %v, got %v", c.patch, c.errStr, err)
+		}
+	}
+	//failed patch doesn't change target
+	if cfg.Servers[0].Subnet.String() != "10.0.1.0/24" {
+		t.Fatalf("target is changed by failed patch %+v", cfg)
+	}
+}
+
+type patchHidden struct {
+	Name   string
+	Hidden string `skipyamlmarshal:""`
+	count  int
+	Sub    *patchHidden
+}
+
+func TestPatchKeepHidden(t *testing.T) {
+	cfg := &patchHidden{Name: "a", Hidden: "h", count: 1, Sub: &patchHidden{Name: "b", Hidden: "sh", count: 2}}
+	err := extyaml.PatchExt(cfg, []byte("name: x\nsub:\n  name: y\n"), extyaml.MergePatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "x" || cfg.Hidden != "h" || cfg.count != 1 || cfg.Sub.Name != "y" || cfg.Sub.Hidden != "sh" || cfg.Sub.count != 2 {
+		t.Fatalf("unexpected result %+v %+v", cfg, cfg.Sub)
+	}
+	err = extyaml.PatchExt(cfg, []byte("- {op: replace, path: /sub/name, value: z}"), extyaml.JSONPatch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "x" || cfg.Hidden != "h" || cfg.count != 1 || cfg.Sub.Name != "z" || cfg.Sub.Hidden != "sh" || cfg.Sub.count != 2 {
+		t.Fatalf("unexpected result %+v %+v", cfg, cfg.Sub)
+	}
+}
+
+func TestJSONPatchTestSecret(t *testing.T) {
+	cfg := &secretConfig{Auth: secretAuth{User: "u", Password: "p1"}}
+	for _, c := range []struct {
+		patch, expect string
+	}{
+		{"- {op: test, path: /auth/password, value: x}", "value is ******"},
+		{"- {op: test, path: /auth, value: {user: x}}", "value is {user: u, password: '******'}"},
+		{"- {op: test, path: /auth/user, value: x}", "value is u"},
+	} {
+		err := extyaml.PatchExt(cfg, []byte(c.patch), extyaml.JSONPatch)
+		if err == nil || !strings.Contains(err.Error(), c.expect) || strings.Contains(err.Error(), "p1") {
+			t.Fatalf("patch %v expect error %v, got %v", c.patch, c.expect, err)
+		}
+	}
+	err := extyaml.PatchExt(&encryptConfig{Password: "p1"}, []byte("- {op: test, path: /password, value: x}"), extyaml.JSONPatch)
+	if err == nil || !strings.Contains(err.Error(), "value is ******") {
+		t.Fatalf("expect masked encrypted value in error, got %v", err)
+	}
+	err = extyaml.PatchExt(cfg, []byte("- {op: test, path: /auth/password, value: x}"), extyaml.JSONPatch, extyaml.Unredacted())
+	if err == nil || !strings.Contains(err.Error(), "value is p1") {
+		t.Fatalf("expect unredacted value in error, got %v", err)
+	}
+}
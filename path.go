@@ -0,0 +1,390 @@
+package extyaml
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A path identifies a value in a YAML document by keys and sequence indexes, e.g. "servers[1].port";
+// a key that contains any of `.[]"` or is empty is quoted in brackets, e.g. `labels["a.b"]`.
+
+// appendKey returns the path of mapping key under path p
+func appendKey(p, key string) string {
+	if key == "" || strings.ContainsAny(key, `.[]"`) {
+		return p + "[" + strconv.Quote(key) + "]"
+	}
+	if p == "" {
+		return key
+	}
+	return p + "." + key
+}
+
+// appendIndex returns the path of sequence item i under path p
+func appendIndex(p string, i int) string {
+	return p + "[" + strconv.Itoa(i) + "]"
+}
+
+// parsePath parses path p into its keys and indexes, an index is kept as a string key
+func parsePath(p string) ([]string, error) {
+	var r []string
+	for i := 0; i < len(p); {
+		switch p[i] {
+		case '.':
+			if i == 0 || i == len(p)-1 || p[i+1] == '.' || p[i+1] == '[' {
+				return nil, fmt.Errorf("invalid path %q: unexpected '.' at %d", p, i)
+			}
+			i++
+		case '[':
+			end := strings.IndexByte(p[i:], ']')
+			if i+1 < len(p) && p[i+1] == '"' {
+				//quoted key, find the closing quote
+				s, err := strconv.QuotedPrefix(p[i+1:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: %w", p, err)
+				}
+				end = len(s) + 1
+				if i+end >= len(p) || p[i+end] != ']' {
+					return nil, fmt.Errorf("invalid path %q: missing ']' at %d", p, i+end)
+				}
+				k, _ := strconv.Unquote(s)
+				r = append(r, k)
+				i += end + 1
+				continue
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ']'", p)
+			}
+			r = append(r, p[i+1:i+end])
+			i += end + 1
+		default:
+			end := strings.IndexAny(p[i:], ".[")
+			if end < 0 {
+				end = len(p) - i
+			}
+			if i > 0 && p[i-1] == ']' {
+				return nil, fmt.Errorf("invalid path %q: missing '.' at %d", p, i)
+			}
+			r = append(r, p[i:i+end])
+			i += end
+		}
+	}
+	return r, nil
+}
+
+// GetPath returns the value in obj at path p rendered via registered codecs, e.g. GetPath(cfg, "servers[0].subnet");
+// p consists of YAML keys separated by '.' and index or map key in brackets, a quoted key in brackets could contain any character,
+// e.g. `labels["a.b"]`; a non-scalar value is rendered in YAML flow style.
+// values of secret fields are rendered as SecretMask unless Unredacted option is specified.
+func GetPath(obj any, p string, opts ...Option) (string, error) {
+	v, secret, err := lookupPath(obj, p)
+	if err != nil {
+		return "", err
+	}
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return "null", nil
+	}
+	return renderValue(v, secret, newOptions(opts))
+}
+
+// renderValue renders v via renderNode, secret is true if v is in a secret field;
+// secret values are masked unless o.unredacted
+func renderValue(v reflect.Value, secret bool, o *options) (string, error) {
+	n, err := marshalNode(v.Interface(), o)
+	if err != nil {
+		return "", err
+	}
+	if !o.unredacted {
+		if secret {
+			maskNode(n)
+		} else {
+			redact(n, v.Type())
+		}
+	}
+	return renderNode(n), nil
+}
+
+// lookupPath returns the value in obj at path p, secret is true if the value is in a secret field
+func lookupPath(obj any, p string) (v reflect.Value, secret bool, err error) {
+	keys, err := parsePath(p)
+	if err != nil {
+		return reflect.Value{}, false, err
+	}
+	v = reflect.ValueOf(obj)
+	cur := ""
+	for _, k := range keys {
+		for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, false, fmt.Errorf("%v is nil", pathName(cur))
+			}
+			v = v.Elem()
+		}
+		next, err := childValue(v, k)
+		if err != nil {
+			return reflect.Value{}, false, fmt.Errorf("%v: %w", pathName(cur), err)
+		}
+		if !next.IsValid() {
+			return reflect.Value{}, false, fmt.Errorf("%v: key %v not found", pathName(cur), k)
+		}
+		if v.Kind() == reflect.Struct {
+			if f, ok := yamlFieldByKey(v.Type(), k); ok && isSecretField(f.field) {
+				secret = true
+			}
+		}
+		cur = appendPathKey(cur, v, k)
+		v = next
+	}
+	return v, secret, nil
+}
+
+// appendPathKey appends key k of container v to path p
+func appendPathKey(p string, v reflect.Value, k string) string {
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		i, _ := strconv.Atoi(k)
+		return appendIndex(p, i)
+	}
+	return appendKey(p, k)
+}
+
+// childValue returns the value of key k in container v, an invalid value if k is a map key that doesn't exist
+func childValue(v reflect.Value, k string) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Struct:
+		if isCodecType(v.Type()) {
+			break
+		}
+		f, ok := yamlFieldByKey(v.Type(), k)
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("unknown key %v", k)
+		}
+		r := fieldByIndex(v, f.index, false)
+		if !r.IsValid() {
+			return reflect.Value{}, fmt.Errorf("key %v is in a nil inline struct", k)
+		}
+		return r, nil
+	case reflect.Map:
+		key, err := parseMapKey(v.Type().Key(), k)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return v.MapIndex(key), nil
+	case reflect.Slice, reflect.Array:
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= v.Len() {
+			return reflect.Value{}, fmt.Errorf("invalid index %v", k)
+		}
+		return v.Index(i), nil
+	}
+	return reflect.Value{}, fmt.Errorf("%v is not a container", v.Type())
+}
+
+// parseMapKey parses s into a map key of type t
+func parseMapKey(t reflect.Type, s string) (reflect.Value, error) {
+	k := reflect.New(t)
+	err := parseValue(k.Elem(), s)
+	return k.Elem(), err
+}
+
+// parseValue parses s via registered codecs and sets v to the result, v must be settable;
+// a value that is not scalar is parsed as YAML, e.g. "[a, b]"
+func parseValue(v reflect.Value, s string) error {
+	t := v.Type()
+	if t.Kind() == reflect.Pointer {
+		e := reflect.New(t.Elem())
+		err := parseValue(e.Elem(), s)
+		if err != nil {
+			return err
+		}
+		v.Set(e)
+		return nil
+	}
+	if !isCodecType(t) && t.Kind() == reflect.String {
+		v.SetString(s)
+		return nil
+	}
+	n := &yaml.Node{Kind: yaml.ScalarNode, Value: s}
+	switch t.Kind() {
+	case reflect.Struct, reflect.Map, reflect.Slice, reflect.Array, reflect.Interface:
+		if !isCodecType(t) {
+			var doc yaml.Node
+			if err := yaml.Unmarshal([]byte(s), &doc); err != nil {
+				return err
+			}
+			n = &doc
+		}
+	}
+	if n.Kind != yaml.DocumentNode {
+		n = &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{n}}
+	}
+	r := reflect.New(t)
+	err := unmarshalNode(n, r.Interface(), newOptions(nil))
+	if err != nil {
+		var perr *PosError
+		if errors.As(err, &perr) && perr.Line == 0 {
+			//the position of a scalar value is meaningless
+			return perr.Err
+		}
+		return err
+	}
+	v.Set(r.Elem())
+	return nil
+}
+
+// SetPath parses value via registered codecs, e.g. FromStr or TextUnmarshaler, and sets it into obj at path p,
+// obj must be a pointer; nil pointers and maps along the path are allocated, index len(slice) appends a new element.
+// See GetPath for the format of p.
+func SetPath(obj any, p, value string) error {
+	keys, err := parsePath(p)
+	if err != nil {
+		return err
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return fmt.Errorf("the object to set is not a pointer")
+	}
+	return setPath(v.Elem(), keys, value, "")
+}
+
+func setPath(v reflect.Value, keys []string, value, cur string) error {
+	if len(keys) == 0 {
+		err := parseValue(v, value)
+		if err != nil {
+			return fmt.Errorf("%v: %w", pathName(cur), err)
+		}
+		return nil
+	}
+	k := keys[0]
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setPath(v.Elem(), keys, value, cur)
+	case reflect.Map:
+		key, err := parseMapKey(v.Type().Key(), k)
+		if err != nil {
+			return fmt.Errorf("%v: %w", pathName(cur), err)
+		}
+		//map element is not addressable, set a copy then set it back
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if e := v.MapIndex(key); e.IsValid() {
+			elem.Set(e)
+		}
+		err = setPath(elem, keys[1:], value, appendKey(cur, k))
+		if err != nil {
+			return err
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		v.SetMapIndex(key, elem)
+		return nil
+	case reflect.Slice:
+		if i, err := strconv.Atoi(k); err == nil && i == v.Len() {
+			v.Set(reflect.Append(v, reflect.New(v.Type().Elem()).Elem()))
+		}
+	case reflect.Struct:
+		if f, ok := yamlFieldByKey(v.Type(), k); ok && !isCodecType(v.Type()) {
+			return setPath(fieldByIndex(v, f.index, true), keys[1:], value, appendKey(cur, k))
+		}
+	}
+	next, err := childValue(v, k)
+	if err != nil {
+		return fmt.Errorf("%v: %w", pathName(cur), err)
+	}
+	if !next.CanSet() {
+		return fmt.Errorf("%v: can't set %v", pathName(cur), k)
+	}
+	return setPath(next, keys[1:], value, appendPathKey(cur, v, k))
+}
+
+// pathValue is a value to set at path, name is the name of its source used in error, e.g. environment variable name
+type pathValue struct {
+	name, path, value string
+}
+
+// slicePaths returns the paths of the slices in type t along path p, outermost first
+func slicePaths(t reflect.Type, p string) []string {
+	keys, _ := parsePath(p)
+	var r []string
+	cur := ""
+	for _, k := range keys {
+		t = indirectType(t)
+		if isCodecType(t) {
+			break
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := yamlFieldByKey(t, k)
+			if !ok {
+				return r
+			}
+			t = f.field.Type
+			cur = appendKey(cur, k)
+			continue
+		case reflect.Slice, reflect.Array:
+			if t.Kind() == reflect.Slice {
+				r = append(r, cur)
+			}
+			i, _ := strconv.Atoi(k)
+			cur = appendIndex(cur, i)
+		case reflect.Map:
+			cur = appendKey(cur, k)
+		default:
+			return r
+		}
+		t = t.Elem()
+	}
+	return r
+}
+
+// setPaths sets list into obj via SetPath in path order, so that slice elements are appended by index;
+// the existing elements of a slice are removed before setting its first element, unless the slice itself is in list.
+func setPaths(obj any, list []pathValue) error {
+	sort.SliceStable(list, func(i, j int) bool { return comparePath(list[i].path, list[j].path) })
+	t := reflect.TypeOf(obj)
+	reset := make(map[string]bool)
+	for _, pv := range list {
+		for _, s := range slicePaths(t, pv.path) {
+			if reset[s] {
+				continue
+			}
+			reset[s] = true
+			if err := SetPath(obj, s, "[]"); err != nil {
+				return fmt.Errorf("%v: %w", pv.name, err)
+			}
+		}
+		if err := SetPath(obj, pv.path, pv.value); err != nil {
+			if pv.name == "" {
+				return err
+			}
+			return fmt.Errorf("%v: %w", pv.name, err)
+		}
+		reset[pv.path] = true
+	}
+	return nil
+}
+
+// comparePath compares path a and b key by key, indexes are compared as numbers
+func comparePath(a, b string) bool {
+	ka, _ := parsePath(a)
+	kb, _ := parsePath(b)
+	for i := 0; i < len(ka) && i < len(kb); i++ {
+		if ka[i] == kb[i] {
+			continue
+		}
+		na, erra := strconv.Atoi(ka[i])
+		nb, errb := strconv.Atoi(kb[i])
+		if erra == nil && errb == nil {
+			return na < nb
+		}
+		return ka[i] < kb[i]
+	}
+	return len(ka) < len(kb)
+}
@@ -0,0 +1,115 @@
+package extyaml_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type pathServer struct {
+	Name    string
+	Subnet  net.IPNet
+	Port    *int
+	Timeout time.Duration `yaml:"time_out"`
+}
+
+type pathConfig struct {
+	Servers []pathServer
+	Mac     net.HardwareAddr
+	Labels  map[string]string
+	Ports   map[int]*pathServer
+	Pair    [2]int
+	Sub     *pathServer
+	Hidden  string `skipyamlmarshal:""`
+}
+
+func TestGetSetPath(t *testing.T) {
+	cfg := &pathConfig{
+		Servers: []pathServer{{Name: "s1", Subnet: mustCIDR("10.0.0.0/24")}},
+		Labels:  map[string]string{"a.b": "x"},
+	}
+	for _, c := range []struct {
+		path, value string
+	}{
+		{"servers[0].subnet", "10.0.1.0/24"},
+		{"servers[0].time_out", "5s"},
+		{"servers[0].port", "8080"},
+		{"servers[1].name", "s2"},
+		{"mac", "11:22:33:44:55:66"},
+		{`labels["a.b"]`, "y"},
+		{"labels.c", "123"},
+		{"ports[80].name", "web"},
+		{"pair[1]", "3"},
+		{"sub.subnet", "192.168.0.0/16"},
+	} {
+		err := extyaml.SetPath(cfg, c.path, c.value)
+		if err != nil {
+			t.Fatalf("failed to set %v, %v", c.path, err)
+		}
+		v, err := extyaml.GetPath(cfg, c.path)
+		if err != nil {
+			t.Fatalf("failed to get %v, %v", c.path, err)
+		}
+		if v != c.value {
+			t.Fatalf("%v is %v, expect %v", c.path, v, c.value)
+		}
+	}
+	if cfg.Servers[0].Subnet.String() != "10.0.1.0/24" || *cfg.Servers[0].Port != 8080 || cfg.Servers[0].Timeout != 5*time.Second {
+		t.Fatalf("unexpected server %+v", cfg.Servers[0])
+	}
+	if len(cfg.Servers) != 2 || cfg.Ports[80].Name != "web" || cfg.Sub == nil || cfg.Labels["c"] != "123" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	v, err := extyaml.GetPath(cfg, "servers[1].port")
+	if err != nil || v != "null" {
+		t.Fatalf("expect null, got %v %v", v, err)
+	}
+	err = extyaml.SetPath(cfg, "pair", "[5, 6]")
+	if err != nil || cfg.Pair != [2]int{5, 6} {
+		t.Fatalf("failed to set pair, %v %v", cfg.Pair, err)
+	}
+
+	for _, c := range []struct {
+		path, value, err string
+	}{
+		{"servers[0].subnet", "10.0.0.300/24", "servers[0].subnet"},
+		{"servers[5].name", "x", "servers: invalid index 5"},
+		{"hidden", "x", "unknown key hidden"},
+		{"mac.x", "x", "mac: "},
+		{"servers[0", "x", "missing ']'"},
+		{"servers..name", "x", "unexpected '.'"},
+	} {
+		err := extyaml.SetPath(cfg, c.path, c.value)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Fatalf("set %v, expect error %q, got %v", c.path, c.err, err)
+		}
+	}
+	_, err = extyaml.GetPath(cfg, "labels.none")
+	if err == nil || !strings.Contains(err.Error(), "labels: key none not found") {
+		t.Fatalf("expect not found error, got %v", err)
+	}
+}
+
+func TestGetPathSecret(t *testing.T) {
+	cfg := &secretConfig{Auth: secretAuth{User: "u", Password: "p1"}, Tokens: []string{"t1"}}
+	for _, c := range []struct {
+		path, expect string
+	}{
+		{"auth.password", "******"},
+		{"auth", "{user: u, password: '******'}"},
+		{"tokens[0]", "******"},
+		{"auth.user", "u"},
+	} {
+		v, err := extyaml.GetPath(cfg, c.path)
+		if err != nil || v != c.expect {
+			t.Fatalf("path %v expect %v, got %v, %v", c.path, c.expect, v, err)
+		}
+	}
+	v, err := extyaml.GetPath(cfg, "auth.password", extyaml.Unredacted())
+	if err != nil || v != "p1" {
+		t.Fatalf("unexpected result %v, %v", v, err)
+	}
+}
@@ -0,0 +1,125 @@
+package extyaml_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type recursiveRoute struct {
+	Prefix   net.IPNet
+	Next     *recursiveRoute
+	Children []*recursiveRoute
+	Policies map[string]recursiveRoute
+}
+
+type recursiveTree map[string]recursiveTree
+
+func TestRecursive(t *testing.T) {
+	in := recursiveRoute{
+		Prefix: mustCIDR("10.0.0.0/8"),
+		Next:   &recursiveRoute{Prefix: mustCIDR("10.1.0.0/16")},
+		Children: []*recursiveRoute{
+			{
+				Prefix: mustCIDR("10.2.0.0/16"),
+				Children: []*recursiveRoute{
+					{Prefix: mustCIDR("10.2.1.0/24")},
+				},
+			},
+		},
+		Policies: map[string]recursiveRoute{
+			"p1": {Prefix: mustCIDR("10.3.0.0/16")},
+		},
+	}
+	buf, err := extyaml.MarshalExt(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `prefix: 10.0.0.0/8
+next:
+    prefix: 10.1.0.0/16
+    next: null
+    children: []
+    policies: {}
+children:
+    - prefix: 10.2.0.0/16
+      next: null
+      children:
+        - prefix: 10.2.1.0/24
+          next: null
+          children: []
+          policies: {}
+      policies: {}
+policies:
+    p1:
+        prefix: 10.3.0.0/16
+        next: null
+        children: []
+        policies: {}
+`
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+	out := new(recursiveRoute)
+	err = extyaml.UnmarshalExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Prefix.String() != "10.0.0.0/8" || out.Next == nil || out.Next.Prefix.String() != "10.1.0.0/16" || out.Next.Next != nil {
+		t.Fatalf("unexpected unmarshal result %+v", out)
+	}
+	if len(out.Children) != 1 || len(out.Children[0].Children) != 1 || out.Children[0].Children[0].Prefix.String() != "10.2.1.0/24" {
+		t.Fatalf("unexpected unmarshal children %+v", out.Children)
+	}
+	if p := out.Policies["p1"]; p.Prefix.String() != "10.3.0.0/16" {
+		t.Fatalf("unexpected unmarshal policies %+v", out.Policies)
+	}
+
+	//partial unmarshal keeps existing value in recursive field
+	err = extyaml.UnmarshalExt([]byte("next:\n  children:\n    - prefix: 10.9.0.0/16\n"), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Next.Prefix.String() != "10.1.0.0/16" || len(out.Next.Children) != 1 || out.Next.Children[0].Prefix.String() != "10.9.0.0/16" {
+		t.Fatalf("unexpected partial unmarshal result %+v", out.Next)
+	}
+
+	//error in recursive field
+	err = extyaml.UnmarshalExt([]byte("next:\n  next:\n    prefix: 10.9.0.0/33\n"), out)
+	if err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expect error at line 3, got %v", err)
+	}
+
+	tree := recursiveTree{"a": {"b": {"c": nil}}}
+	buf, err = extyaml.MarshalExt(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outTree := recursiveTree{}
+	err = extyaml.UnmarshalExt(buf, &outTree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := outTree["a"]["b"]["c"]; !ok {
+		t.Fatalf("unexpected unmarshal tree %v", outTree)
+	}
+}
+
+type recursiveDynamic struct {
+	Value any
+	Next  *recursiveDynamic
+}
+
+func TestRecursiveOptions(t *testing.T) {
+	//options apply to nested values of recursive type, e.g. EmitTags for dynamic values
+	in := recursiveDynamic{Value: mustCIDR("10.0.0.0/8"), Next: &recursiveDynamic{Value: mustCIDR("10.1.0.0/16")}}
+	buf, err := extyaml.MarshalExt(in, extyaml.EmitTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(buf), "!cidr ") != 2 {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+}
@@ -9,6 +9,10 @@ type registeredType struct {
 	origType, exType reflect.Type
 	fromStr          FromStr
 	toStr            ToStr
+	//tag is the local YAML tag of the type
+	tag string
+	//aliasTags are other tags accepted when unmarshalling, e.g. the default tag replaced by WithTag
+	aliasTags []string
 }
 
 // GetTypeName returns a name string for the type t
@@ -19,10 +23,18 @@ func GetTypeName(t reflect.Type) string {
 // Registry maintains all registed types
 type Registry struct {
 	origToExtTypeList map[string]*registeredType
+	tagResolvers      map[string]TagResolver
+	naming            NamingStrategy
+	jsonTagFallback   bool
+	caseInsensitive   bool
+	keyProvider       KeyProvider
 }
 
 // RegisteredTypes is the global Registry
-var RegisteredTypes = Registry{origToExtTypeList: make(map[string]*registeredType)}
+var RegisteredTypes = Registry{
+	origToExtTypeList: make(map[string]*registeredType),
+	tagResolvers:      make(map[string]TagResolver),
+}
 
 func (reg *Registry) isSupportedType(t reflect.Type, isCheckingOrig bool) (ok bool) {
 	if isCheckingOrig {
@@ -45,16 +57,21 @@ func (reg *Registry) Get(typename string) *registeredType {
 	return reg.origToExtTypeList[typename]
 }
 
-// RegisterExt register a new type, with supplied to,from function,
+// RegisterExt register a new type, with supplied to,from function and options,
 // should be called in init()
-func RegisterExt[T any](to ToStr, from FromStr) {
+func RegisterExt[T any](to ToStr, from FromStr, opts ...RegisterOption) {
 	ext := newGeneralExt[T]()
 	origType := reflect.TypeOf(*new(T))
 	exType := reflect.TypeOf(ext)
-	RegisteredTypes.origToExtTypeList[GetTypeName(origType)] = &registeredType{
+	rt := &registeredType{
 		origType: origType,
 		exType:   exType,
 		toStr:    to,
 		fromStr:  from,
+		tag:      defaultTag(origType),
+	}
+	for _, opt := range opts {
+		opt(rt)
 	}
+	RegisteredTypes.origToExtTypeList[GetTypeName(origType)] = rt
 }
@@ -0,0 +1,217 @@
+package extyaml
+
+import (
+	"reflect"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RemainOpt is the ExtTag option of the field that collects unknown keys, e.g. `extyaml:",remain"`;
+// the field type must be map[string]yaml.Node or *yaml.Node
+const RemainOpt = "remain"
+
+// remainKey is the YAML key of remain field in converted type,
+// unknown keys are moved under it when unmarshalling, and moved back when marshaling
+const remainKey = "\x00extyaml-remain"
+
+var (
+	yamlNodeType    = reflect.TypeOf(yaml.Node{})
+	yamlNodePtrType = reflect.TypeOf(&yaml.Node{})
+	remainMapType   = reflect.TypeOf(map[string]yaml.Node{})
+)
+
+func isRemainField(field reflect.StructField) bool {
+	return parseExtTag(field.Tag).has(RemainOpt)
+}
+
+// remainField returns the index of the remain field of struct type t, nil if there is none
+func remainField(t reflect.Type) []int {
+	for i := 0; i < t.NumField(); i++ {
+		if isRemainField(t.Field(i)) {
+			return []int{i}
+		}
+	}
+	return nil
+}
+
+// collectRemain moves the unknown keys of mappings in n that maps to a struct with remain field under remainKey,
+// t is the Go type n maps to
+func collectRemain(n *yaml.Node, t reflect.Type) error {
+	return walkNode(n, t, func(n *yaml.Node, t reflect.Type) error {
+		if t == nil || t.Kind() != reflect.Struct || n.Kind != yaml.MappingNode || remainField(t) == nil {
+			return nil
+		}
+		remain := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		known := n.Content[:0:0]
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k := n.Content[i]
+			if _, ok := yamlFieldByKey(t, k.Value); ok || k.ShortTag() == "!!merge" {
+				known = append(known, k, n.Content[i+1])
+				continue
+			}
+			remain.Content = append(remain.Content, k, n.Content[i+1])
+		}
+		if len(remain.Content) == 0 {
+			return nil
+		}
+		n.Content = append(known, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: remainKey}, remain)
+		return nil
+	})
+}
+
+// spliceRemain moves the keys under remainKey back into their parent mappings in n, at the position of remainKey,
+// a key that is already in the parent mapping is dropped
+func spliceRemain(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		var remain *yaml.Node
+		pos := -1
+		content := n.Content[:0:0]
+		keys := make(map[string]bool)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i].Value == remainKey {
+				remain, pos = n.Content[i+1], len(content)
+				continue
+			}
+			keys[n.Content[i].Value] = true
+			content = append(content, n.Content[i], n.Content[i+1])
+		}
+		if remain != nil && remain.Kind == yaml.MappingNode {
+			var spliced []*yaml.Node
+			for i := 0; i+1 < len(remain.Content); i += 2 {
+				if !keys[remain.Content[i].Value] {
+					spliced = append(spliced, remain.Content[i], remain.Content[i+1])
+				}
+			}
+			content = append(content[:pos], append(spliced, content[pos:]...)...)
+		}
+		n.Content = content
+	}
+	for _, c := range n.Content {
+		spliceRemain(c)
+	}
+}
+
+// dropKnownKeys removes the entries of the remain field in struct v whose key is a field of struct type t,
+// v is either a struct of type t or its converted type; such an entry is stale, e.g. it was added to the remain field
+// directly, or it was collected before the field was added to t
+func dropKnownKeys(v reflect.Value, t reflect.Type) {
+	idx := remainField(t)
+	if idx == nil {
+		return
+	}
+	known := func(k string) bool {
+		_, ok := yamlFieldByKey(t, k)
+		return ok
+	}
+	filter := func(n *yaml.Node) *yaml.Node {
+		if n == nil || n.Kind != yaml.MappingNode {
+			return n
+		}
+		r := *n
+		r.Content = nil
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if !known(n.Content[i].Value) {
+				r.Content = append(r.Content, n.Content[i], n.Content[i+1])
+			}
+		}
+		return &r
+	}
+	f := v.FieldByIndex(idx)
+	switch f.Type() {
+	case remainMapType:
+		for _, k := range f.MapKeys() {
+			if known(k.String()) {
+				f.SetMapIndex(k, reflect.Value{})
+			}
+		}
+	case yamlNodePtrType:
+		if !f.IsNil() {
+			f.Set(reflect.ValueOf(filter(f.Interface().(*yaml.Node))))
+		}
+	case yamlNodeType:
+		n := f.Interface().(yaml.Node)
+		if n.Kind != 0 {
+			f.Set(reflect.ValueOf(*filter(&n)))
+		}
+	}
+}
+
+// remainToNode converts map m of remain field into a mapping node,
+// keys are in the order of their position in the source document, new keys are sorted and placed after
+func remainToNode(m map[string]yaml.Node) yaml.Node {
+	r := yaml.Node{}
+	if len(m) == 0 {
+		return r
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := m[keys[i]], m[keys[j]]
+		switch {
+		case a.Line == 0 || b.Line == 0:
+			if a.Line != b.Line {
+				return b.Line == 0
+			}
+		case a.Line != b.Line:
+			return a.Line < b.Line
+		case a.Column != b.Column:
+			return a.Column < b.Column
+		}
+		return keys[i] < keys[j]
+	})
+	r.Kind, r.Tag = yaml.MappingNode, "!!map"
+	for _, k := range keys {
+		v := m[k]
+		r.Content = append(r.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: k}, &v)
+	}
+	return r
+}
+
+// translateRemain translates between remain field and its converted type yaml.Node, return false if in/out is not;
+// a yaml.Node of zero kind means no unknown key, which leaves remain field untouched
+func translateRemain(in any, rV reflect.Value, toExt bool) bool {
+	if toExt {
+		if rV.Type().Elem() != yamlNodeType {
+			return false
+		}
+		switch v := in.(type) {
+		case map[string]yaml.Node:
+			rV.Elem().Set(reflect.ValueOf(remainToNode(v)))
+		case *yaml.Node:
+			if v == nil {
+				rV.Elem().Set(reflect.Zero(yamlNodeType))
+			} else {
+				rV.Elem().Set(reflect.ValueOf(*v))
+			}
+		default:
+			return false
+		}
+		return true
+	}
+	n, ok := in.(yaml.Node)
+	if !ok {
+		return false
+	}
+	switch rV.Type().Elem() {
+	case remainMapType:
+		if n.Kind != yaml.MappingNode {
+			return true
+		}
+		if rV.Elem().IsNil() {
+			rV.Elem().Set(reflect.MakeMap(remainMapType))
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			rV.Elem().SetMapIndex(reflect.ValueOf(n.Content[i].Value), reflect.ValueOf(*n.Content[i+1]))
+		}
+	case yamlNodePtrType:
+		if n.Kind != 0 {
+			rV.Elem().Set(reflect.ValueOf(&n))
+		}
+	default:
+		return false
+	}
+	return true
+}
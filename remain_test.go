@@ -0,0 +1,141 @@
+package extyaml_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+	"gopkg.in/yaml.v3"
+)
+
+type remainServer struct {
+	Name   string
+	Subnet net.IPNet
+	Rest   *yaml.Node `extyaml:",remain"`
+}
+
+type remainConfig struct {
+	Name    string
+	Servers []remainServer
+	Rest    map[string]yaml.Node `extyaml:",remain"`
+}
+
+func TestRemain(t *testing.T) {
+	doc := `zeta: 1
+name: n1
+servers:
+    - name: s1
+      weight: 10
+      subnet: 10.0.0.0/8
+      tags: [a, b]
+alpha:
+    nested: true
+beta: x
+`
+	cfg := new(remainConfig)
+	err := extyaml.UnmarshalExt([]byte(doc), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Name != "n1" || len(cfg.Servers) != 1 || cfg.Servers[0].Subnet.String() != "10.0.0.0/8" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	if len(cfg.Rest) != 3 || cfg.Rest["beta"].Value != "x" {
+		t.Fatalf("unexpected remain %+v", cfg.Rest)
+	}
+	if rest := cfg.Servers[0].Rest; rest == nil || len(rest.Content) != 4 || rest.Content[0].Value != "weight" {
+		t.Fatalf("unexpected server remain %+v", rest)
+	}
+	buf, err := extyaml.MarshalExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `name: n1
+servers:
+    - name: s1
+      subnet: 10.0.0.0/8
+      weight: 10
+      tags: [a, b]
+zeta: 1
+alpha:
+    nested: true
+beta: x
+`
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+
+	//new entry and entry conflicts with known key
+	cfg.Rest["gamma"] = yaml.Node{Kind: yaml.ScalarNode, Value: "g"}
+	cfg.Rest["name"] = yaml.Node{Kind: yaml.ScalarNode, Value: "dup"}
+	cfg.Servers[0].Rest = nil
+	buf, err = extyaml.MarshalExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `name: n1
+servers:
+    - name: s1
+      subnet: 10.0.0.0/8
+zeta: 1
+alpha:
+    nested: true
+beta: x
+gamma: g
+`
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+
+	//precise partial unmarshal
+	err = extyaml.UnmarshalExt([]byte("delta: 4\n"), cfg, extyaml.OnlyPresentKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Rest["delta"].Value != "4" || cfg.Rest["beta"].Value != "x" || cfg.Name != "n1" {
+		t.Fatalf("unexpected partial result %+v", cfg)
+	}
+}
+
+type remainFirst struct {
+	Rest  map[string]yaml.Node `extyaml:",remain"`
+	Name  string
+	Port  int               `yaml:",omitempty"`
+	Items map[string]string `yaml:",omitempty"`
+}
+
+func TestRemainStale(t *testing.T) {
+	cfg := new(remainFirst)
+	err := extyaml.UnmarshalExt([]byte("name: n\nb: 2\na: 1\n"), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//unknown keys are placed at the position of remain field, in original order
+	buf, err := extyaml.MarshalExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "b: 2\na: 1\nname: \"n\"\n"
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+
+	//stale entries of known keys are not emitted, even if the known key is omitted
+	cfg.Rest["port"] = yaml.Node{Kind: yaml.ScalarNode, Value: "8080"}
+	cfg.Rest["items"] = yaml.Node{Kind: yaml.ScalarNode, Value: "x"}
+	buf, err = extyaml.MarshalExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+	//and are dropped when unmarshalling
+	err = extyaml.UnmarshalExt([]byte("c: 3\n"), cfg, extyaml.OnlyPresentKeys())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Rest["port"]; ok || len(cfg.Rest) != 3 || cfg.Rest["c"].Value != "3" || cfg.Name != "n" {
+		t.Fatalf("unexpected remain %+v", cfg.Rest)
+	}
+}
@@ -0,0 +1,100 @@
+package extyaml
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TagResolver is the function resolves a node with a local YAML tag (e.g. "!file") into a new node,
+// the returned node is then unmarshalled as usual
+type TagResolver func(n *yaml.Node) (*yaml.Node, error)
+
+// RegisterTagResolver registers fn as the resolver of YAML tag, e.g. "!file";
+// tagged values are resolved by UnmarshalExt before reaching the field's codec,
+// should be called in init()
+func (reg *Registry) RegisterTagResolver(tag string, fn TagResolver) {
+	if reg.tagResolvers == nil {
+		reg.tagResolvers = make(map[string]TagResolver)
+	}
+	reg.tagResolvers[tag] = fn
+}
+
+// UnregisterTagResolver removes the resolver of YAML tag registered via RegisterTagResolver
+func (reg *Registry) UnregisterTagResolver(tag string) {
+	delete(reg.tagResolvers, tag)
+}
+
+// resolveTags replaces every node under n that has a registered tag with the resolved node, t is the type of n;
+// a resolved string is kept as string for a string field, a registered type or an unknown key,
+// otherwise its tag is removed so that it is resolved as usual, e.g. "8080" for an int field
+func (reg *Registry) resolveTags(n *yaml.Node, t reflect.Type) error {
+	if len(reg.tagResolvers) == 0 {
+		return nil
+	}
+	return walkNode(n, t, func(n *yaml.Node, t reflect.Type) error {
+		fn, ok := reg.tagResolvers[n.Tag]
+		if !ok {
+			return nil
+		}
+		r, err := fn(n)
+		if err != nil {
+			return newPosError(n, fmt.Errorf("failed to resolve %v: %w", n.Tag, err))
+		}
+		if r.Line == 0 {
+			r.Line, r.Column = n.Line, n.Column
+		}
+		if r.Kind == yaml.ScalarNode && r.Tag == "!!str" && r.Style == 0 &&
+			t != nil && t.Kind() != reflect.String && !isCodecType(t) {
+			r.Tag = ""
+		}
+		*n = *r
+		return nil
+	})
+}
+
+// newScalarNode returns a string scalar node of value s
+func newScalarNode(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// FileTagResolver returns a TagResolver that resolves to the content of the file in fsys,
+// the path is the tagged value, with leading "/" and trailing newline removed, e.g. "!file /etc/keys/token"
+func FileTagResolver(fsys fs.FS) TagResolver {
+	return func(n *yaml.Node) (*yaml.Node, error) {
+		buf, err := fs.ReadFile(fsys, strings.TrimLeft(n.Value, "/"))
+		if err != nil {
+			return nil, err
+		}
+		return newScalarNode(strings.TrimRight(string(buf), "\r\n")), nil
+	}
+}
+
+// Base64TagResolver is a TagResolver that resolves to the base64 (standard encoding) decoded tagged value, e.g. "!base64 aGVsbG8="
+func Base64TagResolver(n *yaml.Node) (*yaml.Node, error) {
+	buf, err := base64.StdEncoding.DecodeString(strings.TrimSpace(n.Value))
+	if err != nil {
+		return nil, err
+	}
+	return newScalarNode(string(buf)), nil
+}
+
+// SecretProvider returns the secret of specified name
+type SecretProvider interface {
+	Secret(name string) (string, error)
+}
+
+// SecretTagResolver returns a TagResolver that resolves to the secret from p, tagged value is the name of the secret, e.g. "!secret db-password"
+func SecretTagResolver(p SecretProvider) TagResolver {
+	return func(n *yaml.Node) (*yaml.Node, error) {
+		s, err := p.Secret(n.Value)
+		if err != nil {
+			return nil, err
+		}
+		return newScalarNode(s), nil
+	}
+}
@@ -0,0 +1,126 @@
+package extyaml_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"testing/fstest"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type mapSecretProvider map[string]string
+
+func (p mapSecretProvider) Secret(name string) (string, error) {
+	if s, ok := p[name]; ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("secret %v not found", name)
+}
+
+type resolverConfig struct {
+	Token    string
+	Subnet   net.IPNet
+	Password string
+	Port     int
+	Macs     []net.HardwareAddr
+}
+
+func TestTagResolver(t *testing.T) {
+	extyaml.RegisteredTypes.RegisterTagResolver("!file", extyaml.FileTagResolver(fstest.MapFS{
+		"etc/keys/token": {Data: []byte("tk123\n")},
+		"etc/mac":        {Data: []byte("11:22:33:44:55:66")},
+	}))
+	extyaml.RegisteredTypes.RegisterTagResolver("!base64", extyaml.Base64TagResolver)
+	extyaml.RegisteredTypes.RegisterTagResolver("!secret", extyaml.SecretTagResolver(mapSecretProvider{
+		"db-password": "pass",
+		"port":        "8080",
+	}))
+	t.Cleanup(func() {
+		extyaml.RegisteredTypes.UnregisterTagResolver("!file")
+		extyaml.RegisteredTypes.UnregisterTagResolver("!base64")
+		extyaml.RegisteredTypes.UnregisterTagResolver("!secret")
+	})
+	buf := []byte(`token: !file /etc/keys/token
+subnet: !base64 MTAuMC4wLjAvOA==
+password: !secret db-password
+port: !secret port
+macs:
+  - !file etc/mac
+  - 22:33:44:55:66:77
+`)
+	cfg := new(resolverConfig)
+	err := extyaml.UnmarshalExt(buf, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "tk123" || cfg.Subnet.String() != "10.0.0.0/8" || cfg.Password != "pass" || cfg.Port != 8080 {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+	if len(cfg.Macs) != 2 || cfg.Macs[0].String() != "11:22:33:44:55:66" {
+		t.Fatalf("unexpected macs %v", cfg.Macs)
+	}
+	err = extyaml.UnmarshalExt([]byte("password: !secret unknown\n"), cfg)
+	var perr *extyaml.PosError
+	if !errors.As(err, &perr) || perr.Line != 1 {
+		t.Fatalf("expect a PosError at line 1, got %v", err)
+	}
+}
+
+type resolverStrConfig struct {
+	Token string
+	Flag  string
+	Port  int
+	Value any
+}
+
+func TestTagResolverString(t *testing.T) {
+	extyaml.RegisteredTypes.RegisterTagResolver("!file", extyaml.FileTagResolver(fstest.MapFS{
+		"null":  {Data: []byte("null\n")},
+		"true":  {Data: []byte("true")},
+		"port":  {Data: []byte("0x10")},
+		"float": {Data: []byte("1e3")},
+	}))
+	t.Cleanup(func() {
+		extyaml.RegisteredTypes.UnregisterTagResolver("!file")
+	})
+	buf := []byte(`token: !file null
+flag: !file true
+port: !file port
+value: !file float
+`)
+	cfg := new(resolverStrConfig)
+	err := extyaml.UnmarshalExt(buf, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "null" || cfg.Flag != "true" || cfg.Port != 16 || cfg.Value != "1e3" {
+		t.Fatalf("unexpected result %+v", cfg)
+	}
+}
+
+func TestUnregisterTagResolver(t *testing.T) {
+	extyaml.RegisteredTypes.RegisterTagResolver("!base64", extyaml.Base64TagResolver)
+	t.Cleanup(func() {
+		extyaml.RegisteredTypes.UnregisterTagResolver("!base64")
+	})
+	buf := []byte("token: !base64 aGVsbG8=\n")
+	cfg := new(resolverConfig)
+	err := extyaml.UnmarshalExt(buf, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "hello" {
+		t.Fatalf("unexpected token %v", cfg.Token)
+	}
+	extyaml.RegisteredTypes.UnregisterTagResolver("!base64")
+	cfg = new(resolverConfig)
+	err = extyaml.UnmarshalExt(buf, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Token != "aGVsbG8=" {
+		t.Fatalf("expect the tag not resolved after unregistering, got %v", cfg.Token)
+	}
+}
@@ -0,0 +1,61 @@
+package extyaml
+
+import (
+	"reflect"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// SecretOpt is the ExtTag option of a field that is redacted in marshaling output, e.g. `extyaml:"secret"`
+	SecretOpt = "secret"
+	// SecretMask replaces every value of a secret field in marshaling output
+	SecretMask = "******"
+)
+
+// Unredacted is an Option that marshals secret fields with their real values
+func Unredacted() Option {
+	return func(o *options) {
+		o.unredacted = true
+	}
+}
+
+// MarshalExtUnredacted marshal in into YAML bytes like MarshalExt, but secret fields have their real values
+func MarshalExtUnredacted(in any, opts ...Option) ([]byte, error) {
+	return MarshalExt(in, append(opts, Unredacted())...)
+}
+
+func isSecretField(field reflect.StructField) bool {
+	return parseExtTag(field.Tag).has(SecretOpt)
+}
+
+// redact masks the values of secret fields in n, t is the Go type n maps to
+func redact(n *yaml.Node, t reflect.Type) {
+	fieldValues(n, t, SecretOpt, func(v *yaml.Node) error {
+		maskNode(v)
+		return nil
+	})
+}
+
+// maskNode replaces every non-null scalar under n with SecretMask, keeping map keys and sequence length
+func maskNode(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.ShortTag() == "!!null" {
+			return
+		}
+		n.Value = SecretMask
+		n.Tag = ""
+		n.Style = 0
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			maskNode(n.Content[i])
+		}
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			maskNode(c)
+		}
+	case yaml.AliasNode:
+		*n = yaml.Node{Kind: yaml.ScalarNode, Value: SecretMask}
+	}
+}
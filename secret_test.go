@@ -0,0 +1,86 @@
+package extyaml_test
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type secretAuth struct {
+	User     string
+	Password string `extyaml:"secret"`
+}
+
+type secretConfig struct {
+	Name   string
+	Auth   secretAuth
+	Key    net.HardwareAddr  `extyaml:"secret"`
+	Tokens []string          `extyaml:"secret"`
+	Keys   map[string]string `extyaml:"secret"`
+	Peers  []secretAuth
+	Token  *string `extyaml:"secret"`
+}
+
+func TestSecret(t *testing.T) {
+	cfg := &secretConfig{
+		Name:   "cfg",
+		Auth:   secretAuth{User: "u", Password: "p1"},
+		Key:    net.HardwareAddr{1, 2, 3, 4, 5, 6},
+		Tokens: []string{"t1", "t2"},
+		Keys:   map[string]string{"k": "v"},
+		Peers:  []secretAuth{{User: "peer", Password: "p2"}},
+	}
+	buf, err := extyaml.MarshalExt(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := `name: cfg
+auth:
+    user: u
+    password: '******'
+key: '******'
+tokens:
+    - '******'
+    - '******'
+keys:
+    k: '******'
+peers:
+    - user: peer
+      password: '******'
+token: null
+`
+	if string(buf) != expect {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+
+	buf, err = extyaml.MarshalExtUnredacted(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(secretConfig)
+	err = extyaml.UnmarshalExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Auth.Password != "p1" || out.Key.String() != cfg.Key.String() || out.Tokens[1] != "t2" || out.Peers[0].Password != "p2" {
+		t.Fatalf("unexpected result %+v", out)
+	}
+
+	def := &secretConfig{Name: "cfg", Auth: secretAuth{User: "u", Password: "p0"}}
+	buf, err = extyaml.MarshalExtDefault(&secretConfig{Name: "cfg", Auth: secretAuth{User: "u", Password: "p1"}}, def)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "auth:\n    password: '******'\n" {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+	buf, err = extyaml.MarshalExtDefault(&secretConfig{Name: "cfg", Auth: secretAuth{User: "u", Password: "p1"}}, def, extyaml.Unredacted())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(buf), "password: p1") {
+		t.Fatalf("unexpected output\n%v", string(buf))
+	}
+}
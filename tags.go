@@ -0,0 +1,82 @@
+package extyaml
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RegisterOption customizes a registered type
+type RegisterOption func(rt *registeredType)
+
+// WithTag sets the local YAML tag of the registered type, e.g. "!mac";
+// the default tag is "!" followed by the lower case type name, it is still accepted when unmarshalling.
+func WithTag(tag string) RegisterOption {
+	return func(rt *registeredType) {
+		if !strings.HasPrefix(tag, "!") {
+			tag = "!" + tag
+		}
+		if rt.tag != "" && rt.tag != tag {
+			rt.aliasTags = append(rt.aliasTags, rt.tag)
+		}
+		rt.tag = tag
+	}
+}
+
+// EmitTags makes registered values marshaled with the local tag of its registered type, e.g. "!cidr 10.0.0.0/8",
+// so that the output is self-describing
+func EmitTags() Option {
+	return func(o *options) {
+		o.emitTags = true
+	}
+}
+
+// defaultTag returns the default tag of registered type t
+func defaultTag(t reflect.Type) string {
+	return "!" + strings.ToLower(t.Name())
+}
+
+// getByTag returns the registered type with tag or alias tag, nil if no such type
+func (reg *Registry) getByTag(tag string) *registeredType {
+	if tag == "" || tag == "!" {
+		return nil
+	}
+	for _, rt := range reg.origToExtTypeList {
+		if rt.tag == tag {
+			return rt
+		}
+	}
+	for _, rt := range reg.origToExtTypeList {
+		for _, alias := range rt.aliasTags {
+			if alias == tag {
+				return rt
+			}
+		}
+	}
+	return nil
+}
+
+// checkTag returns an error if n has the tag of a registered type other than rt
+func (reg *Registry) checkTag(n *yaml.Node, rt *registeredType) error {
+	other := reg.getByTag(n.Tag)
+	if other == nil || other == rt {
+		return nil
+	}
+	return newPosError(n, fmt.Errorf("tag %v is for %v, but expecting %v (%v)", n.Tag, other.origType, rt.origType, rt.tag))
+}
+
+// addTags adds the tag of registered type to the scalar nodes in n, t is the Go type n maps to
+func addTags(n *yaml.Node, t reflect.Type) {
+	walkNode(n, t, func(n *yaml.Node, t reflect.Type) error {
+		if t == nil || n.Kind != yaml.ScalarNode || n.ShortTag() == "!!null" {
+			return nil
+		}
+		if rt := RegisteredTypes.Get(GetTypeName(t)); rt != nil && rt.origType == t {
+			n.Tag = rt.tag
+			n.Style &^= yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+		}
+		return nil
+	})
+}
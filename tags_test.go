@@ -0,0 +1,116 @@
+package extyaml_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type tagVLAN struct {
+	ID int
+}
+
+func init() {
+	extyaml.RegisterExt[tagVLAN](func(in any) (string, error) {
+		return fmt.Sprintf("vlan%d", in.(tagVLAN).ID), nil
+	}, func(s string) (any, error) {
+		id, err := strconv.Atoi(strings.TrimPrefix(s, "vlan"))
+		if err != nil {
+			return nil, err
+		}
+		return tagVLAN{ID: id}, nil
+	}, extyaml.WithTag("vlan"))
+}
+
+type tagConfig struct {
+	Mac    net.HardwareAddr
+	Subnet *net.IPNet
+	Nil    *net.IPNet
+	VLANs  []tagVLAN
+	Name   string
+	Attrs  map[string]any
+}
+
+func TestTags(t *testing.T) {
+	subnet := mustCIDR("10.0.0.0/8")
+	in := tagConfig{
+		Mac:    net.HardwareAddr{0, 0x11, 0x22, 0x33, 0x44, 0x55},
+		Subnet: &subnet,
+		VLANs:  []tagVLAN{{ID: 100}},
+		Name:   "n",
+		Attrs:  map[string]any{"subnet": mustCIDR("10.1.0.0/16")},
+	}
+	buf, err := extyaml.MarshalExt(in, extyaml.EmitTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `mac: !mac 00:11:22:33:44:55
+subnet: !cidr 10.0.0.0/8
+nil: null
+vlans:
+    - !vlan vlan100
+name: "n"
+attrs:
+    subnet: !cidr 10.1.0.0/16
+`
+	if string(buf) != expected {
+		t.Fatalf("marshal result %v is different from expected %v", string(buf), expected)
+	}
+	out := new(tagConfig)
+	err = extyaml.UnmarshalExt(buf, out, extyaml.DecodeDynamicTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Mac.String() != "00:11:22:33:44:55" || out.Subnet.String() != "10.0.0.0/8" || out.Nil != nil ||
+		len(out.VLANs) != 1 || out.VLANs[0].ID != 100 {
+		t.Fatalf("unexpected unmarshal result %+v", out)
+	}
+	if n, ok := out.Attrs["subnet"].(net.IPNet); !ok || n.String() != "10.1.0.0/16" {
+		t.Fatalf("unexpected attrs %#v", out.Attrs)
+	}
+
+	//tag of another registered type
+	err = extyaml.UnmarshalExt([]byte("name: n\nmac: !cidr 10.0.0.0/8\n"), out)
+	var perr *extyaml.PosError
+	if !errors.As(err, &perr) || perr.Line != 2 || !strings.Contains(err.Error(), "!cidr") {
+		t.Fatalf("expect tag error at line 2, got %v", err)
+	}
+}
+
+func TestTagsDefaultAlias(t *testing.T) {
+	//the default tags before WithTag are still accepted
+	doc := `mac: !hardwareaddr 00:11:22:33:44:55
+subnet: !ipnet 10.0.0.0/8
+attrs:
+  old: !ipnet 10.1.0.0/16
+  new: !cidr 10.2.0.0/16
+  mac: !mac 00:11:22:33:44:66
+`
+	out := new(tagConfig)
+	err := extyaml.UnmarshalExt([]byte(doc), out, extyaml.DecodeDynamicTags())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Mac.String() != "00:11:22:33:44:55" || out.Subnet.String() != "10.0.0.0/8" {
+		t.Fatalf("unexpected unmarshal result %+v", out)
+	}
+	if n, ok := out.Attrs["old"].(net.IPNet); !ok || n.String() != "10.1.0.0/16" {
+		t.Fatalf("unexpected attrs %#v", out.Attrs)
+	}
+	if n, ok := out.Attrs["new"].(net.IPNet); !ok || n.String() != "10.2.0.0/16" {
+		t.Fatalf("unexpected attrs %#v", out.Attrs)
+	}
+	if m, ok := out.Attrs["mac"].(net.HardwareAddr); !ok || m.String() != "00:11:22:33:44:66" {
+		t.Fatalf("unexpected attrs %#v", out.Attrs)
+	}
+	//alias tag of another registered type
+	err = extyaml.UnmarshalExt([]byte("mac: !ipnet 10.0.0.0/8\n"), out)
+	if err == nil || !strings.Contains(err.Error(), "!ipnet") {
+		t.Fatalf("expect tag error, got %v", err)
+	}
+}
@@ -82,3 +82,32 @@ func TestIPNet(t *testing.T) {
 		testFunc(t, c)
 	}
 }
+
+func TestInvalidIPNet(t *testing.T) {
+	//an invalid CIDR used to panic on nil pointer dereference
+	out := &testStruct[net.IPNet]{}
+	err := extyaml.UnmarshalExt([]byte("val: 192.168.1.0/33"), out)
+	if err == nil {
+		t.Fatal("expect an error of invalid CIDR")
+	}
+}
+
+func TestZeroIPNet(t *testing.T) {
+	//zero value is marshaled as "" instead of "<nil>", so that it could be unmarshalled back
+	in := testStruct[net.IPNet]{}
+	buf, err := extyaml.MarshalExt(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != "val: \"\"\n" {
+		t.Fatalf("unexpected marshal result %v", string(buf))
+	}
+	out := &testStruct[net.IPNet]{Val: mustCIDR("10.0.0.0/8")}
+	err = extyaml.UnmarshalExt(buf, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Val.IP != nil || out.Val.Mask != nil {
+		t.Fatalf("unexpected unmarshal result %v", out.Val)
+	}
+}
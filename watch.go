@@ -0,0 +1,184 @@
+package extyaml
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultWatchInterval is the default polling interval of Watcher
+const DefaultWatchInterval = time.Second
+
+// Watcher polls a YAML file, without relying on platform specific file notification, and reloads it into a value of type T when its content changes;
+// the file is decoded via UnmarshalExt, so registered types are used and PostUnmarshal is called.
+// if the new content fails to decode, OnError is called and the last good value is kept.
+// OnChange and OnError must be set before Start, they are called from the polling goroutine, and from the caller of Check.
+type Watcher[T any] struct {
+	//OnChange is called with the old and new value and their differences when the value changes,
+	//values of secret fields in the differences are SecretMask unless Unredacted option is passed to NewWatcher;
+	//it could call Stop, but not Check
+	OnChange func(old, new T, changes []Change)
+	//OnError is called when the file can't be read or decoded, it could call Stop
+	OnError func(err error)
+
+	path     string
+	interval time.Duration
+	opts     []Option
+
+	//checkMu serializes check, so that concurrent checks don't commit and report changes out of order
+	checkMu sync.Mutex
+	mu      sync.Mutex
+	value   T
+	//content is the last content read from the file
+	content []byte
+	stop    chan struct{}
+	done    chan struct{}
+	//inCallback is true when the polling goroutine is calling OnChange or OnError
+	inCallback bool
+}
+
+// NewWatcher returns a Watcher of file path polled every interval (DefaultWatchInterval if interval <= 0),
+// opts are passed to UnmarshalExt and DiffExt; the file is loaded once and an error is returned if it fails.
+func NewWatcher[T any](path string, interval time.Duration, opts ...Option) (*Watcher[T], error) {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	w := &Watcher[T]{
+		path:     path,
+		interval: interval,
+		opts:     append([]Option{WithSource(path)}, opts...),
+	}
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	v, err := w.decode(buf)
+	if err != nil {
+		return nil, err
+	}
+	w.value = v
+	w.content = buf
+	return w, nil
+}
+
+func (w *Watcher[T]) decode(buf []byte) (T, error) {
+	r := new(T)
+	err := UnmarshalExt(buf, r, w.opts...)
+	return *r, err
+}
+
+// Value returns the last good value
+func (w *Watcher[T]) Value() T {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.value
+}
+
+// Check reads the file once, if its content changed, decodes it and calls OnChange if the value changed;
+// the error is also passed to OnError. Check is safe to call while polling, the checks are serialized,
+// so OnChange must not call Check.
+func (w *Watcher[T]) Check() error {
+	return w.checkAndReport(false)
+}
+
+// checkAndReport calls check and passes the error to OnError, poller is true if it is called from the polling goroutine
+func (w *Watcher[T]) checkAndReport(poller bool) error {
+	err := w.check(poller)
+	if err != nil && w.OnError != nil {
+		w.callback(poller, func() { w.OnError(err) })
+	}
+	return err
+}
+
+// callback calls fn which calls OnChange or OnError, poller is true if it is called from the polling goroutine;
+// Stop called meanwhile doesn't wait for the polling goroutine, since the goroutine could be the caller of Stop
+func (w *Watcher[T]) callback(poller bool, fn func()) {
+	if poller {
+		w.mu.Lock()
+		w.inCallback = true
+		w.mu.Unlock()
+		defer func() {
+			w.mu.Lock()
+			w.inCallback = false
+			w.mu.Unlock()
+		}()
+	}
+	fn()
+}
+
+func (w *Watcher[T]) check(poller bool) error {
+	w.checkMu.Lock()
+	defer w.checkMu.Unlock()
+	buf, err := os.ReadFile(w.path)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	if bytes.Equal(buf, w.content) {
+		w.mu.Unlock()
+		return nil
+	}
+	old := w.value
+	w.mu.Unlock()
+	v, err := w.decode(buf)
+	if err != nil {
+		//the error is reported once for the same content
+		w.mu.Lock()
+		w.content = buf
+		w.mu.Unlock()
+		return err
+	}
+	changes, err := DiffExt(old, v, w.opts...)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.value = v
+	w.content = buf
+	w.mu.Unlock()
+	if len(changes) > 0 && w.OnChange != nil {
+		w.callback(poller, func() { w.OnChange(old, v, changes) })
+	}
+	return nil
+}
+
+// Start starts polling the file in a new goroutine until Stop is called
+func (w *Watcher[T]) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil {
+		return
+	}
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				w.checkAndReport(true)
+			}
+		}
+	}(w.stop, w.done)
+}
+
+// Stop stops polling and waits for the polling goroutine to exit; if the goroutine is calling OnChange or OnError,
+// e.g. Stop is called from them, Stop returns without waiting, and the goroutine exits after the callback returns
+func (w *Watcher[T]) Stop() {
+	w.mu.Lock()
+	stop, done, inCallback := w.stop, w.done, w.inCallback
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+	if stop == nil {
+		return
+	}
+	close(stop)
+	if !inCallback {
+		<-done
+	}
+}
@@ -0,0 +1,227 @@
+package extyaml_test
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hujun-open/extyaml"
+)
+
+type watchConfig struct {
+	Name   string
+	Subnet net.IPNet
+	Port   int
+}
+
+func (c *watchConfig) PostUnmarshal() error {
+	if c.Port < 0 {
+		return errors.New("invalid port")
+	}
+	return nil
+}
+
+func TestWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	write := func(s string) {
+		//replace the file atomically so that the watcher never reads a partial file
+		if err := os.WriteFile(path+".tmp", []byte(s), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("name: a\nsubnet: 10.0.0.0/24\nport: 80\n")
+	w, err := extyaml.NewWatcher[watchConfig](path, 10*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type event struct {
+		old, new watchConfig
+		changes  []extyaml.Change
+	}
+	events := make(chan event, 10)
+	errs := make(chan error, 10)
+	w.OnChange = func(old, new watchConfig, changes []extyaml.Change) {
+		events <- event{old, new, changes}
+	}
+	w.OnError = func(err error) { errs <- err }
+	w.Start()
+	defer w.Stop()
+
+	write("name: a\nsubnet: 10.0.1.0/24\nport: 80\n")
+	select {
+	case e := <-events:
+		if e.old.Subnet.String() != "10.0.0.0/24" || e.new.Subnet.String() != "10.0.1.0/24" ||
+			len(e.changes) != 1 || e.changes[0].String() != "subnet: 10.0.0.0/24 -> 10.0.1.0/24" {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change event")
+	}
+
+	//invalid value keeps the last good value
+	write("name: a\nsubnet: 10.0.1.0/24\nport: -1\n")
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "invalid port") {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no error event")
+	}
+	if w.Value().Port != 80 {
+		t.Fatalf("unexpected value %+v", w.Value())
+	}
+
+	write("name: b\nsubnet: 10.0.1.0/24\nport: 80\n")
+	select {
+	case e := <-events:
+		if e.old.Name != "a" || e.new.Name != "b" || len(e.changes) != 1 {
+			t.Fatalf("unexpected event %+v", e)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no change event")
+	}
+	w.Stop()
+
+	//content change without value change doesn't call OnChange
+	write("# comment\nname: b\nsubnet: 10.0.1.0/24\nport: 80\n")
+	if err := w.Check(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-events:
+		t.Fatalf("unexpected event %+v", e)
+	case err := <-errs:
+		t.Fatalf("unexpected error %v", err)
+	default:
+	}
+
+	write("name: [\n")
+	_, err = extyaml.NewWatcher[watchConfig](path, 0)
+	if err == nil {
+		t.Fatal("expect error for invalid file")
+	}
+}
+
+func TestWatcherConcurrentCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("port: 0\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	w, err := extyaml.NewWatcher[watchConfig](path, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//OnChange is called from both the polling goroutine and Check, serialized by the watcher
+	var events [][2]int
+	w.OnChange = func(old, new watchConfig, changes []extyaml.Change) {
+		events = append(events, [2]int{old.Port, new.Port})
+	}
+	w.Start()
+	defer w.Stop()
+	const total = 50
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < total*4; i++ {
+			w.Check()
+		}
+	}()
+	for i := 1; i <= total; i++ {
+		if err := os.WriteFile(path+".tmp", []byte("port: "+strconv.Itoa(i)+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			t.Fatal(err)
+		}
+		w.Check()
+	}
+	<-done
+	w.Stop()
+	if w.Value().Port != total {
+		t.Fatalf("unexpected value %+v", w.Value())
+	}
+	//every change starts from the value committed by the previous one
+	last := 0
+	for _, e := range events {
+		if e[0] != last || e[1] <= e[0] {
+			t.Fatalf("out of order events %v", events)
+		}
+		last = e[1]
+	}
+}
+
+func TestWatcherSecret(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	for _, unredacted := range []bool{false, true} {
+		if err := os.WriteFile(path, []byte("auth: {password: p1}\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		var opts []extyaml.Option
+		expect := "auth.password: ****** -> ******"
+		if unredacted {
+			opts = append(opts, extyaml.Unredacted())
+			expect = "auth.password: p1 -> p2"
+		}
+		w, err := extyaml.NewWatcher[secretConfig](path, time.Hour, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var changes []extyaml.Change
+		w.OnChange = func(old, new secretConfig, c []extyaml.Change) { changes = c }
+		if err := os.WriteFile(path, []byte("auth: {password: p2}\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Check(); err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 1 || changes[0].String() != expect {
+			t.Fatalf("unexpected changes %v", changes)
+		}
+	}
+}
+
+func TestWatcherStopInCallback(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	for _, content := range []string{"port: 1\n", "port: -1\n"} {
+		if err := os.WriteFile(path, []byte("port: 0\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		w, err := extyaml.NewWatcher[watchConfig](path, time.Millisecond)
+		if err != nil {
+			t.Fatal(err)
+		}
+		//Stop from OnChange or OnError returns without waiting for the polling goroutine that calls them
+		stopped := make(chan struct{})
+		w.OnChange = func(old, new watchConfig, changes []extyaml.Change) {
+			w.Stop()
+			close(stopped)
+		}
+		w.OnError = func(err error) {
+			w.Stop()
+			close(stopped)
+		}
+		w.Start()
+		if err := os.WriteFile(path+".tmp", []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(path+".tmp", path); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-stopped:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Stop in callback of %q doesn't return", content)
+		}
+		//stopped already
+		w.Stop()
+	}
+}